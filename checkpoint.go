@@ -0,0 +1,509 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CheckpointConfig enables checkpointing of a crawl so an interrupted Run can resume
+type CheckpointConfig struct {
+	Path     string `yaml:"path,omitempty" json:"path,omitempty"`         // checkpoint file used by the default FileCheckpointStore
+	Interval int    `yaml:"interval,omitempty" json:"interval,omitempty"` // save after every N completed iterations/pages (default: 1)
+}
+
+// CheckpointStore persists crawl progress as a log of records. Records are
+// only ever appended, so saving costs the same at the first and at the last
+// iteration of a crawl. The crawler serializes calls and does not change the
+// records once they are appended.
+type CheckpointStore interface {
+	// Load returns the appended records in order, or nil if there are none
+	Load() ([]*CheckpointRecord, error)
+	// Append adds records to the end of the log
+	Append(records []*CheckpointRecord) error
+	// Clear removes the saved records
+	Clear() error
+}
+
+// Checkpoint record kinds
+const (
+	checkpointStep      = "step"      // a top-level step finished
+	checkpointIteration = "iteration" // a forEach/forValues iteration completed
+	checkpointPage      = "page"      // a page of a paginated request completed
+)
+
+// CheckpointRecord is a unit of progress of a step execution: a finished
+// top-level step, a completed iteration or a completed page
+type CheckpointRecord struct {
+	Kind      string             `json:"kind"`
+	StepPath  string             `json:"stepPath"`
+	Scope     string             `json:"scope,omitempty"`     // indices of the enclosing iterations/pages (e.g. "3/0")
+	Index     int                `json:"index,omitempty"`     // iteration or page index
+	Result    any                `json:"result,omitempty"`    // forEach iteration result
+	Paginator *PaginatorState    `json:"paginator,omitempty"` // paginator state to continue after the page
+	Merges    []*CheckpointMerge `json:"merges,omitempty"`    // merges done by the step, iteration or page
+}
+
+// CheckpointMerge is a merge into a context that outlives the iteration or page
+// that did it. Contexts are not saved: on resume, the merges of the completed
+// iterations and pages are replayed in order to rebuild them.
+type CheckpointMerge struct {
+	Seq      int            `json:"seq"`              // order of the merge in the crawl
+	StepPath string         `json:"stepPath"`         // step whose merge rule is replayed
+	Context  int            `json:"context"`          // position of the target in the context chain
+	Result   any            `json:"result,omitempty"` // merged result ($res)
+	Vars     map[string]any `json:"vars,omitempty"`   // template context read by the merge rule ($ctx)
+	Patch    bool           `json:"patch,omitempty"`  // default forEach merge of the iteration results
+	Take     bool           `json:"take,omitempty"`   // the data of the context was streamed
+
+	scope string // checkpoint scope of the step execution that merged
+	page  int    // page of a request merge, -1 for other merges
+}
+
+// checkpointEntry is the progress of a step execution rebuilt from the
+// records of a previous run
+type checkpointEntry struct {
+	done      bool               // top-level step finished
+	completed map[int]bool       // completed forEach/forValues iterations
+	results   map[int]any        // forEach iteration results by index
+	paginator *PaginatorState    // request pagination state after the last completed page
+	merges    []*CheckpointMerge // merges to replay before the step continues
+}
+
+// isCompleted reports whether iteration index was completed
+func (e *checkpointEntry) isCompleted(index int) bool {
+	return e != nil && e.completed[index]
+}
+
+// FileCheckpointStore stores the checkpoint as a file of JSON lines, one per record
+type FileCheckpointStore struct {
+	Path string
+}
+
+// NewFileCheckpointStore creates a checkpoint store backed by the given file
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{Path: path}
+}
+
+func (s *FileCheckpointStore) Load() ([]*CheckpointRecord, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A record cut short by a crash while appending is dropped, so the next
+	// record starts on a line of its own
+	if end := bytes.LastIndexByte(data, '\n') + 1; end < len(data) {
+		if err := os.Truncate(s.Path, int64(end)); err != nil {
+			return nil, err
+		}
+		data = data[:end]
+	}
+
+	var records []*CheckpointRecord
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		// Numbers are read exactly, so large ids in the saved results survive
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var record CheckpointRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint file %s, line %d: %w", s.Path, n+1, err)
+		}
+		record.Result = exactNumbers(record.Result)
+		if record.Paginator != nil {
+			exactNumbers(record.Paginator.Ctx)
+		}
+		for _, merge := range record.Merges {
+			merge.Result = exactNumbers(merge.Result)
+			exactNumbers(merge.Vars)
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// Append writes the records at the end of the file, in a single write
+func (s *FileCheckpointStore) Append(records []*CheckpointRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileCheckpointStore) Clear() error {
+	err := os.Remove(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// checkpointKey identifies a step execution by its path and iteration scope
+func checkpointKey(stepPath string, scope string) string {
+	if scope == "" {
+		return stepPath
+	}
+	return stepPath + "#" + scope
+}
+
+// nestedScope returns the scope of steps nested in iteration (or page) index of a step
+func nestedScope(scope string, index int) string {
+	if scope == "" {
+		return strconv.Itoa(index)
+	}
+	return scope + "/" + strconv.Itoa(index)
+}
+
+// inScope reports whether scope equals or is nested within parent
+func inScope(scope string, parent string) bool {
+	return parent == "" || scope == parent || strings.HasPrefix(scope, parent+"/")
+}
+
+// isNestedStep reports whether stepPath is a step nested in parentPath
+func isNestedStep(stepPath string, parentPath string) bool {
+	return strings.HasPrefix(stepPath, parentPath+".steps[")
+}
+
+// checkpointTracker records progress during a Run and appends it to the store.
+// A nil tracker disables checkpointing; all methods are nil-safe.
+type checkpointTracker struct {
+	store      CheckpointStore
+	interval   int
+	mergeMutex *sync.Mutex // guards context data while copying iteration results
+
+	mu      sync.Mutex
+	resumed map[string]*checkpointEntry // progress loaded from a previous run, consumed on resume
+	merges  []*CheckpointMerge          // merges of iterations, pages and steps still running
+	records []*CheckpointRecord         // records not appended yet
+	seq     int                         // sequence number of the last merge
+}
+
+// newCheckpointTracker loads the saved checkpoint, if any, from the store
+func newCheckpointTracker(store CheckpointStore, interval int, mergeMutex *sync.Mutex) (*checkpointTracker, error) {
+	if interval <= 0 {
+		interval = 1
+	}
+	t := &checkpointTracker{
+		store:      store,
+		interval:   interval,
+		mergeMutex: mergeMutex,
+		resumed:    map[string]*checkpointEntry{},
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading checkpoint: %w", err)
+	}
+	for _, record := range records {
+		if err := t.restore(record); err != nil {
+			return nil, fmt.Errorf("error loading checkpoint: %w", err)
+		}
+	}
+	for _, entry := range t.resumed {
+		sort.SliceStable(entry.merges, func(i, j int) bool {
+			return entry.merges[i].Seq < entry.merges[j].Seq
+		})
+	}
+	return t, nil
+}
+
+// restore adds a record loaded from the store to the progress to resume
+func (t *checkpointTracker) restore(record *CheckpointRecord) error {
+	key := checkpointKey(record.StepPath, record.Scope)
+	e, ok := t.resumed[key]
+	if !ok {
+		e = &checkpointEntry{completed: map[int]bool{}, results: map[int]any{}}
+		t.resumed[key] = e
+	}
+
+	nested := nestedScope(record.Scope, record.Index)
+	switch record.Kind {
+	case checkpointStep:
+		e.done = true
+		nested = record.Scope
+	case checkpointIteration:
+		e.completed[record.Index] = true
+		if record.Result != nil {
+			e.results[record.Index] = record.Result
+		}
+	case checkpointPage:
+		e.paginator = record.Paginator
+	default:
+		return fmt.Errorf("unknown record kind '%s'", record.Kind)
+	}
+	for _, merge := range record.Merges {
+		t.seq = max(t.seq, merge.Seq)
+	}
+	e.merges = append(e.merges, record.Merges...)
+
+	// The nested steps of the completed part do not run again: their
+	// progress is dropped and their merges are replayed with it
+	for key, nestedEntry := range t.resumed {
+		stepPath, scope, _ := strings.Cut(key, "#")
+		if isNestedStep(stepPath, record.StepPath) && inScope(scope, nested) {
+			e.merges = append(e.merges, nestedEntry.merges...)
+			delete(t.resumed, key)
+		}
+	}
+	return nil
+}
+
+// Resuming reports whether a saved checkpoint was loaded
+func (t *checkpointTracker) Resuming() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.resumed) > 0
+}
+
+// resume returns the saved progress of a step execution from a previous run,
+// or nil when there is nothing to resume. Its merges are replayed by the crawler.
+func (t *checkpointTracker) resume(exec *stepExecution) *checkpointEntry {
+	if t == nil || exec.checkpointDisabled {
+		return nil
+	}
+
+	key := checkpointKey(exec.stepPath, exec.scope)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.resumed[key]
+	if !ok {
+		return nil
+	}
+	delete(t.resumed, key)
+	return e
+}
+
+// merged records a merge done by exec into target, at page of a request (-1
+// for other merges). Merges into contexts created within the enclosing
+// iteration or page are not kept: they are covered by its result. The result
+// and vars of merge are copied, so the caller must hold mergeMutex.
+func (t *checkpointTracker) merged(exec *stepExecution, target *Context, page int, merge *CheckpointMerge) {
+	if t == nil {
+		return
+	}
+
+	pos := exec.contexts.position(target)
+	pages := page >= 0 && !exec.checkpointDisabled && exec.step.Request != nil && !exec.step.Request.Pagination.isEmpty()
+	if pos < 0 || (pos >= exec.checkpointChain && !pages) {
+		return
+	}
+
+	merge.StepPath = exec.stepPath
+	merge.Context = pos
+	merge.Result = copyDataSafe(merge.Result)
+	if merge.Vars != nil {
+		merge.Vars = copyDataSafe(merge.Vars).(map[string]any)
+	}
+	merge.scope = exec.scope
+	merge.page = page
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	merge.Seq = t.seq
+	t.merges = append(t.merges, merge)
+}
+
+// takeMerges removes the pending merges selected by done and returns them in order.
+// Must be called with t.mu held.
+func (t *checkpointTracker) takeMerges(done func(merge *CheckpointMerge) bool) []*CheckpointMerge {
+	var taken []*CheckpointMerge
+	pending := t.merges[:0]
+	for _, merge := range t.merges {
+		if done(merge) {
+			taken = append(taken, merge)
+		} else {
+			pending = append(pending, merge)
+		}
+	}
+	clear(t.merges[len(pending):])
+	t.merges = pending
+	return taken
+}
+
+// stepDone records that a top-level step finished. Nested steps are covered by
+// the iteration or page that contains them.
+func (t *checkpointTracker) stepDone(exec *stepExecution) error {
+	if t == nil || exec.checkpointDisabled || exec.scope != "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record := &CheckpointRecord{Kind: checkpointStep, StepPath: exec.stepPath}
+	record.Merges = t.takeMerges(func(merge *CheckpointMerge) bool {
+		return merge.StepPath == exec.stepPath || isNestedStep(merge.StepPath, exec.stepPath)
+	})
+	return t.appendLocked(record, true)
+}
+
+// iterationDone records a completed forEach/forValues iteration. result is the
+// iteration result for forEach (nil for forValues).
+func (t *checkpointTracker) iterationDone(exec *stepExecution, index int, result any) error {
+	if t == nil || exec.checkpointDisabled {
+		return nil
+	}
+
+	if result != nil {
+		t.mergeMutex.Lock()
+		result = copyDataSafe(result)
+		t.mergeMutex.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record := &CheckpointRecord{Kind: checkpointIteration, StepPath: exec.stepPath, Scope: exec.scope, Index: index, Result: result}
+	scope := nestedScope(exec.scope, index)
+	record.Merges = t.takeMerges(func(merge *CheckpointMerge) bool {
+		return isNestedStep(merge.StepPath, exec.stepPath) && inScope(merge.scope, scope)
+	})
+	return t.appendLocked(record, false)
+}
+
+// pageDone records the paginator state after a completed page of a request step
+func (t *checkpointTracker) pageDone(exec *stepExecution, page int, state PaginatorState) error {
+	if t == nil || exec.checkpointDisabled {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record := &CheckpointRecord{Kind: checkpointPage, StepPath: exec.stepPath, Scope: exec.scope, Index: page, Paginator: &state}
+	scope := nestedScope(exec.scope, page)
+	record.Merges = t.takeMerges(func(merge *CheckpointMerge) bool {
+		if merge.StepPath == exec.stepPath && merge.scope == exec.scope {
+			return merge.page >= 0 && merge.page <= page
+		}
+		return isNestedStep(merge.StepPath, exec.stepPath) && inScope(merge.scope, scope)
+	})
+	return t.appendLocked(record, false)
+}
+
+// appendLocked queues a record and appends the queued records to the store
+// once the interval is reached (or immediately if force is set). Only the new
+// records are written, whatever the size of the crawled data.
+// Must be called with t.mu held.
+func (t *checkpointTracker) appendLocked(record *CheckpointRecord, force bool) error {
+	t.records = append(t.records, record)
+	if !force && len(t.records) < t.interval {
+		return nil
+	}
+	if err := t.store.Append(t.records); err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	t.records = nil
+	return nil
+}
+
+// clear removes the saved checkpoint after a successful run
+func (t *checkpointTracker) clear() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = nil
+	t.merges = nil
+	return t.store.Clear()
+}
+
+// replayMerges redoes the merges saved with the completed steps, iterations
+// and pages of exec, in the order they were done. Merges into contexts that
+// exec cannot see were done into contexts created by those iterations and
+// pages, and are covered by their results.
+func (c *ApiCrawler) replayMerges(exec *stepExecution) error {
+	if exec.resumed == nil || len(exec.resumed.merges) == 0 {
+		return nil
+	}
+
+	c.mergeMutex.Lock()
+	defer c.mergeMutex.Unlock()
+
+	for _, merge := range exec.resumed.merges {
+		target, ok := exec.contexts.at(merge.Context)
+		if !ok {
+			continue
+		}
+		if merge.Take {
+			if _, ok := target.Data.([]interface{}); ok {
+				target.Data = []interface{}{}
+			}
+			continue
+		}
+
+		compiled := c.getCompiledStep(merge.StepPath)
+		if compiled == nil {
+			return fmt.Errorf("error replaying checkpoint merge: step %s was not compiled", merge.StepPath)
+		}
+		var updated any
+		var err error
+		switch {
+		case merge.Patch:
+			updated, err = compiled.ExecuteSyntheticMerge(target.Data, merge.Result)
+		case compiled.Merge != nil && compiled.Merge.Rule != nil:
+			vars := merge.Vars
+			if vars == nil {
+				vars = map[string]any{}
+			}
+			updated, err = compiled.Merge.Rule.RunSingle(target.Data, merge.Result, vars)
+		default:
+			updated = defaultMerge(target.Data, merge.Result)
+		}
+		if err != nil {
+			return fmt.Errorf("error replaying checkpoint merge of step %s: %w", merge.StepPath, err)
+		}
+		target.Data = updated
+	}
+	return nil
+}
+
+// mergeVars returns the part of templateCtx read by a merge rule
+func mergeVars(scope *ContextScope, templateCtx map[string]any) map[string]any {
+	if scope.All {
+		return templateCtx
+	}
+	if len(scope.Keys) == 0 {
+		return nil
+	}
+	vars := make(map[string]any, len(scope.Keys))
+	for _, key := range scope.Keys {
+		if v, ok := templateCtx[key]; ok {
+			vars[key] = v
+		}
+	}
+	return vars
+}
@@ -0,0 +1,535 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestRecorder records requested URLs and fails the ones listed in failing
+type requestRecorder struct {
+	mu      sync.Mutex
+	urls    []string
+	failing map[string]bool
+}
+
+func (r *requestRecorder) intercept(req *http.Request, resp *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = append(r.urls, req.URL.String())
+	if r.failing[req.URL.String()] {
+		resp.StatusCode = http.StatusInternalServerError
+	}
+}
+
+func (r *requestRecorder) reset(failing ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = nil
+	r.failing = map[string]bool{}
+	for _, url := range failing {
+		r.failing[url] = true
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.jsonl"))
+
+	loaded, err := store.Load()
+	require.Nil(t, err)
+	assert.Nil(t, loaded, "missing file means no checkpoint")
+
+	require.Nil(t, store.Append([]*CheckpointRecord{
+		{Kind: checkpointIteration, StepPath: "steps[0]", Scope: "0", Index: 0, Result: map[string]any{"id": float64(1)}},
+	}))
+	require.Nil(t, store.Append([]*CheckpointRecord{
+		{Kind: checkpointPage, StepPath: "steps[0].steps[0]", Scope: "0/1", Index: 2,
+			Paginator: &PaginatorState{Ctx: map[string]any{"page": 3}, PageNum: 2},
+			Merges:    []*CheckpointMerge{{Seq: 1, StepPath: "steps[0].steps[0]", Result: []any{"a"}, Vars: map[string]any{"id": "x"}}},
+		},
+	}))
+
+	// A record cut short by a crash is dropped
+	f, err := os.OpenFile(store.Path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.Nil(t, err)
+	_, err = f.WriteString(`{"kind":"iteration","stepPa`)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	loaded, err = store.Load()
+	require.Nil(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, map[string]any{"id": float64(1)}, loaded[0].Result)
+	assert.Equal(t, 2, loaded[1].Paginator.PageNum)
+	assert.Equal(t, []any{"a"}, loaded[1].Merges[0].Result)
+	assert.Equal(t, map[string]any{"id": "x"}, loaded[1].Merges[0].Vars)
+
+	require.Nil(t, store.Append([]*CheckpointRecord{{Kind: checkpointStep, StepPath: "steps[1]"}}))
+	loaded, err = store.Load()
+	require.Nil(t, err)
+	require.Len(t, loaded, 3, "records appended after a truncated one are readable")
+
+	require.Nil(t, store.Clear())
+	_, err = os.Stat(store.Path)
+	assert.True(t, os.IsNotExist(err))
+	require.Nil(t, store.Clear(), "clearing twice is not an error")
+}
+
+func TestPaginatorStateRestore(t *testing.T) {
+	cfg := ConfigP{Pagination: Pagination{
+		Params: []Param{
+			{Name: "page", Location: "query", Type: "int", Default: "1", Increment: "+ 1"},
+			{Name: "from", Location: "query", Type: "datetime", Format: "2006-01-02", Default: "2024-01-01", Increment: "1d"},
+		},
+	}}
+	p, err := NewPaginator(cfg)
+	require.Nil(t, err)
+	require.Nil(t, p.applyIncrements())
+
+	state := p.State()
+	assert.Equal(t, 1, state.PageNum)
+
+	// Simulate a JSON round trip: ints come back as float64
+	state.Ctx["page"] = float64(state.Ctx["page"].(int))
+
+	restored, err := NewPaginator(cfg)
+	require.Nil(t, err)
+	restored.Restore(state)
+	assert.Equal(t, 1, restored.PageNum())
+	assert.Equal(t, map[string]string{"page": "2", "from": "2024-01-02"}, restored.NextFromCtx().QueryParams)
+
+	require.Nil(t, restored.applyIncrements())
+	assert.Equal(t, map[string]string{"page": "3", "from": "2024-01-03"}, restored.NextFromCtx().QueryParams)
+}
+
+func TestCheckpointResumeForEach(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            name: "Fetch details"
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	checkpointPath := filepath.Join(tmpDir, "checkpoint.json")
+
+	responses := map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1},
+			map[string]interface{}{"id": 2},
+			map[string]interface{}{"id": 3},
+			map[string]interface{}{"id": 4},
+		},
+	}
+	for i := 1; i <= 4; i++ {
+		responses[fmt.Sprintf("https://api.example.com/items/%d", i)] = map[string]interface{}{"name": fmt.Sprintf("item %d", i)}
+	}
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(responses)
+	recorder := &requestRecorder{}
+	mockTransport.InterceptFunc = recorder.intercept
+
+	newCrawler := func() *ApiCrawler {
+		craw, _, err := NewApiCrawler(configPath)
+		require.Nil(t, err)
+		craw.SetClient(&http.Client{Transport: mockTransport})
+		craw.SetCheckpointStore(NewFileCheckpointStore(checkpointPath))
+		return craw
+	}
+
+	// First run dies on item 3
+	recorder.reset("https://api.example.com/items/3")
+	err := newCrawler().Run(context.TODO(), nil)
+	require.NotNil(t, err)
+	_, err = os.Stat(checkpointPath)
+	require.Nil(t, err, "checkpoint should be kept after a failed run")
+
+	// Second run resumes at item 3
+	recorder.reset()
+	craw := newCrawler()
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"https://api.example.com/items",
+		"https://api.example.com/items/3",
+		"https://api.example.com/items/4",
+	}, recorder.urls)
+
+	data := craw.GetData().([]interface{})
+	require.Len(t, data, 4)
+	for i, item := range data {
+		details := item.(map[string]interface{})["details"]
+		assert.Equal(t, map[string]interface{}{"name": fmt.Sprintf("item %d", i+1)}, details)
+	}
+
+	_, err = os.Stat(checkpointPath)
+	assert.True(t, os.IsNotExist(err), "checkpoint should be removed after a successful run")
+}
+
+func TestCheckpointResumePagination(t *testing.T) {
+	tmpDir := t.TempDir()
+	checkpointPath := filepath.Join(tmpDir, "checkpoint.json")
+	configContent := fmt.Sprintf(`
+rootContext: []
+checkpoint:
+  path: %s
+steps:
+  - type: request
+    name: "Fetch pages"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "1"
+            increment: "+ 1"
+        stopOn:
+          - type: responseBody
+            expression: '.items | length == 0'
+    resultTransformer: .items
+`, checkpointPath)
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	// Expectations only match query parameters listed explicitly
+	mockTransport := &crawler_testing.MockRoundTripper{}
+	pages := [][]interface{}{{"a", "b"}, {"c", "d"}, {"e"}, {}}
+	for i, items := range pages {
+		mockTransport.Expectations = append(mockTransport.Expectations, crawler_testing.MockExpectation{
+			Request:  crawler_testing.MockRequest{URL: "https://api.example.com/items", QueryParams: map[string]string{"page": fmt.Sprint(i + 1)}},
+			Response: crawler_testing.MockResponse{StatusCode: http.StatusOK, BodyJSON: map[string]interface{}{"items": items}},
+		})
+	}
+	recorder := &requestRecorder{}
+	mockTransport.InterceptFunc = recorder.intercept
+
+	recorder.reset("https://api.example.com/items?page=3")
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+	require.NotNil(t, craw.Run(context.TODO(), nil))
+
+	recorder.reset()
+	craw, _, err = NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []string{
+		"https://api.example.com/items?page=3",
+		"https://api.example.com/items?page=4",
+	}, recorder.urls, "pagination should restart at the saved page")
+	assert.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, craw.GetData())
+}
+
+func TestCheckpointResumeForValues(t *testing.T) {
+	configContent := `
+rootContext: {}
+steps:
+  - type: request
+    name: "Fetch settings"
+    request:
+      url: https://api.example.com/settings
+      method: GET
+    mergeOn: .settings = $res
+  - type: forValues
+    name: "Fetch regions"
+    values: ["north", "south", "east"]
+    as: region
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/regions/{{ .region }}
+          method: GET
+        mergeOn: .[$ctx.region] = $res
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/settings":      map[string]interface{}{"lang": "en"},
+		"https://api.example.com/regions/north": map[string]interface{}{"stations": 3},
+		"https://api.example.com/regions/south": map[string]interface{}{"stations": 5},
+		"https://api.example.com/regions/east":  map[string]interface{}{"stations": 1},
+	})
+	recorder := &requestRecorder{}
+	mockTransport.InterceptFunc = recorder.intercept
+	store := NewFileCheckpointStore(filepath.Join(tmpDir, "checkpoint.json"))
+
+	recorder.reset("https://api.example.com/regions/south")
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+	craw.SetCheckpointStore(store)
+	require.NotNil(t, craw.Run(context.TODO(), nil))
+
+	recorder.reset()
+	craw, _, err = NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+	craw.SetCheckpointStore(store)
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	// The completed top-level step and the first value are not requested again
+	assert.Equal(t, []string{
+		"https://api.example.com/regions/south",
+		"https://api.example.com/regions/east",
+	}, recorder.urls)
+	assert.Equal(t, map[string]interface{}{
+		"settings": map[string]interface{}{"lang": "en"},
+		"north":    map[string]interface{}{"stations": float64(3)},
+		"south":    map[string]interface{}{"stations": float64(5)},
+		"east":     map[string]interface{}{"stations": float64(1)},
+	}, craw.GetData())
+}
+
+func TestCheckpointResumeNestedPages(t *testing.T) {
+	configContent := `
+rootContext: {}
+steps:
+  - type: forValues
+    values: ["x", "y"]
+    as: group
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/{{ .group }}
+          method: GET
+          pagination:
+            params:
+              - name: page
+                location: query
+                type: int
+                default: "1"
+                increment: "+ 1"
+            stopOn:
+              - type: responseBody
+                expression: 'length == 0'
+        mergeWithContext:
+          name: root
+          rule: '.[$ctx.group] = (.[$ctx.group] // []) + $res'
+`
+	pages := map[string]string{
+		"/x?page=1": `[1, 2]`, "/x?page=2": `[3]`, "/x?page=3": `[]`,
+		"/y?page=1": `[4]`, "/y?page=2": `[5, 6]`, "/y?page=3": `[]`,
+	}
+	var requested []string
+	failing := ""
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		page := req.URL.RequestURI()
+		requested = append(requested, page)
+		resp := jsonResponse(req, pages[page])
+		if page == failing {
+			resp.StatusCode = http.StatusInternalServerError
+		}
+		return resp, nil
+	})
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.jsonl"))
+
+	failing = "/y?page=2"
+	craw := newTestCrawler(t, configContent, client)
+	craw.SetCheckpointStore(store)
+	require.NotNil(t, craw.Run(context.TODO(), nil))
+
+	requested, failing = nil, ""
+	craw = newTestCrawler(t, configContent, client)
+	craw.SetCheckpointStore(store)
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	// The merges of the completed value and pages are replayed, not requested again
+	assert.Equal(t, []string{"/y?page=2", "/y?page=3"}, requested)
+	assert.Equal(t, map[string]any{
+		"x": []any{float64(1), float64(2), float64(3)},
+		"y": []any{float64(4), float64(5), float64(6)},
+	}, craw.GetData())
+}
+
+func TestCheckpointResumeTwice(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: forValues
+    values: ["a", "b", "c", "d"]
+    as: region
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/regions/{{ .region }}
+          method: GET
+        mergeOn: '. + $res'
+`
+	var requested []string
+	failing := ""
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		region := filepath.Base(req.URL.Path)
+		requested = append(requested, region)
+		resp := jsonResponse(req, fmt.Sprintf(`["%s"]`, region))
+		if region == failing {
+			resp.StatusCode = http.StatusInternalServerError
+		}
+		return resp, nil
+	})
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.jsonl"))
+	run := func(fail string) (*ApiCrawler, error) {
+		requested, failing = nil, fail
+		craw := newTestCrawler(t, configContent, client)
+		craw.SetCheckpointStore(store)
+		return craw, craw.Run(context.TODO(), nil)
+	}
+
+	_, err := run("b")
+	require.NotNil(t, err)
+	_, err = run("c")
+	require.NotNil(t, err)
+	assert.Equal(t, []string{"b", "c"}, requested)
+
+	craw, err := run("")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"c", "d"}, requested)
+	assert.Equal(t, []any{"a", "b", "c", "d"}, craw.GetData(), "every merge is replayed once, in order")
+}
+
+func TestCheckpointResumeStream(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+steps:
+  - type: forValues
+    values: ["a", "b", "c"]
+    as: region
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/regions/{{ .region }}
+          method: GET
+`
+	failing := "b"
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		region := filepath.Base(req.URL.Path)
+		resp := jsonResponse(req, fmt.Sprintf(`["%s-1", "%s-2"]`, region, region))
+		if region == failing {
+			resp.StatusCode = http.StatusInternalServerError
+		}
+		return resp, nil
+	})
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.jsonl"))
+
+	collect := func() ([]any, error) {
+		craw := newTestCrawler(t, configContent, client)
+		craw.SetCheckpointStore(store)
+		var received []any
+		err := craw.RunEach(context.TODO(), nil, func(output string, entity any) error {
+			received = append(received, entity)
+			return nil
+		})
+		return received, err
+	}
+
+	received, err := collect()
+	require.NotNil(t, err)
+	assert.Equal(t, []any{"a-1", "a-2"}, received)
+
+	failing = ""
+	received, err = collect()
+	require.Nil(t, err)
+	assert.Equal(t, []any{"b-1", "b-2", "c-1", "c-2"}, received, "data streamed before the interruption is not streamed again")
+}
+
+// sizeRecorder is a checkpoint store that keeps the encoded size of every append
+type sizeRecorder struct {
+	sizes []int
+}
+
+func (s *sizeRecorder) Load() ([]*CheckpointRecord, error) { return nil, nil }
+
+func (s *sizeRecorder) Append(records []*CheckpointRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	s.sizes = append(s.sizes, len(data))
+	return nil
+}
+
+func (s *sizeRecorder) Clear() error { return nil }
+
+func TestCheckpointSaveCostIsFlat(t *testing.T) {
+	const iterations = 500
+	values := make([]string, iterations)
+	for i := range values {
+		values[i] = fmt.Sprintf(`"v%03d"`, i)
+	}
+	configContent := fmt.Sprintf(`
+rootContext: {}
+steps:
+  - type: forValues
+    values: [%s]
+    as: region
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/regions/{{ .region }}
+          method: GET
+        mergeOn: '.[$ctx.region] = $res'
+`, strings.Join(values, ", "))
+
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		return `{"stations": 3, "name": "a region with a fairly long name"}`
+	}))
+	store := &sizeRecorder{}
+	craw.SetCheckpointStore(store)
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	require.Len(t, craw.GetData(), iterations)
+
+	// One append per iteration, then one for the finished step. Each iteration
+	// appends its own record, however much data the root context holds.
+	require.Len(t, store.sizes, iterations+1)
+	first, last := store.sizes[1], store.sizes[iterations-1]
+	assert.InDelta(t, first, last, 8, "appending iteration %d costs as much as iteration 1", iterations-1)
+}
+
+func TestValidateCheckpoint(t *testing.T) {
+	errs := ValidateConfig(Config{
+		RootContext: []interface{}{},
+		Checkpoint:  &CheckpointConfig{Path: "checkpoint.json", Interval: -1},
+		Steps: []Step{{
+			Type:    "request",
+			Request: &RequestConfig{URL: "https://api.example.com", Method: "GET"},
+		}},
+	})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "checkpoint.interval", errs[0].Location)
+}
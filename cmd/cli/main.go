@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/noi-techpark/go-silky"
@@ -21,6 +22,9 @@ func main() {
 	profilerFlag := flag.Bool("profiler", false, "Enable profiler output (JSON per step)")
 	validateFlag := flag.Bool("validate", false, "Only validate configuration without running")
 	varsFlag := flag.String("vars", "", "Runtime variables as JSON object (e.g., '{\"key\":\"value\"}')")
+	checkpointFlag := flag.String("checkpoint", "", "Checkpoint file to resume interrupted crawls from (overrides checkpoint.path)")
+	stateFlag := flag.String("state", "", "State file holding incremental watermarks (overrides incremental.stateFile)")
+	outputDirFlag := flag.String("output-dir", "", "Stream mode: write each output to <dir>/<output>.ndjson (the data stream to default.ndjson) instead of stdout")
 	flag.Parse()
 
 	if *configPath == "" {
@@ -60,6 +64,13 @@ func main() {
 		return
 	}
 
+	if *checkpointFlag != "" {
+		crawler.SetCheckpointStore(silky.NewFileCheckpointStore(*checkpointFlag))
+	}
+	if *stateFlag != "" {
+		crawler.SetStateStore(silky.NewFileStateStore(*stateFlag))
+	}
+
 	var wg sync.WaitGroup
 
 	// Enable profiler if requested
@@ -82,37 +93,59 @@ func main() {
 		}()
 	}
 
-	// Handle stream mode if enabled
-	var streamChan chan interface{}
-	streamDone := make(chan bool)
+	// Handle stream mode if enabled: the data stream and every named output
+	writers := make(map[string]func(jsonEntity []byte))
 
 	if crawler.Config.Stream {
-		streamChan = crawler.GetDataStream()
+		if *outputDirFlag != "" {
+			if err := os.MkdirAll(*outputDirFlag, 0755); err != nil {
+				log.Fatalf("Failed to create output directory: %v", err)
+			}
+		}
 
-		// Consume stream and output entities
-		go func() {
-			for entity := range streamChan {
-				jsonEntity, err := json.Marshal(entity)
+		for _, name := range append([]string{""}, crawler.Config.Outputs...) {
+			writers[name] = func(jsonEntity []byte) {
+				if name == "" {
+					fmt.Printf("STREAM: %s\n", string(jsonEntity))
+				} else {
+					fmt.Printf("STREAM[%s]: %s\n", name, string(jsonEntity))
+				}
+			}
+			if *outputDirFlag != "" {
+				fileName := name
+				if fileName == "" {
+					fileName = "default"
+				}
+				file, err := os.Create(filepath.Join(*outputDirFlag, fileName+".ndjson"))
 				if err != nil {
-					log.Printf("Failed to marshal stream entity: %v", err)
-					continue
+					log.Fatalf("Failed to create output file: %v", err)
 				}
-				if !*profilerFlag {
-					fmt.Printf("STREAM: %s\n", string(jsonEntity))
+				defer file.Close()
+				writers[name] = func(jsonEntity []byte) {
+					if _, err := file.Write(append(jsonEntity, '\n')); err != nil {
+						log.Printf("Failed to write output %s: %v", fileName, err)
+					}
 				}
 			}
-			streamDone <- true
-		}()
+		}
 	}
 
-	// Run crawler
+	// Run crawler, consuming the streamed entities in stream mode
 	ctx := context.Background()
-	err = crawler.Run(ctx, vars)
-
-	// Wait for stream to finish if in stream mode
 	if crawler.Config.Stream {
-		close(streamChan)
-		<-streamDone
+		err = crawler.RunEach(ctx, vars, func(output string, entity any) error {
+			jsonEntity, err := json.Marshal(entity)
+			if err != nil {
+				log.Printf("Failed to marshal stream entity: %v", err)
+				return nil
+			}
+			if !*profilerFlag || *outputDirFlag != "" {
+				writers[output](jsonEntity)
+			}
+			return nil
+		})
+	} else {
+		err = crawler.Run(ctx, vars)
 	}
 
 	// Close profilerChan to signal the consumer goroutine to exit
@@ -121,6 +154,18 @@ func main() {
 		wg.Wait() // ✅ Wait until all profiler data is consumed
 	}
 
+	// Output iterations collected by onError: collect, even if the crawl failed later
+	if !*profilerFlag {
+		for _, deadLetter := range crawler.DeadLetters() {
+			jsonDeadLetter, err := json.Marshal(deadLetter)
+			if err != nil {
+				log.Printf("Failed to marshal dead letter: %v", err)
+				continue
+			}
+			fmt.Printf("DEAD_LETTER: %s\n", string(jsonDeadLetter))
+		}
+	}
+
 	if err != nil {
 		log.Fatalf("Crawl failed: %v", err)
 	}
@@ -66,6 +66,8 @@ func getEventTypeName(t silky.ProfileEventType) string {
 		silky.EVENT_RESULT:               "Result",
 		silky.EVENT_STREAM_RESULT:        "Stream Result",
 		silky.EVENT_ERROR:                "Error",
+		silky.EVENT_REQUEST_RETRY:        "Request Retry",
+		silky.EVENT_STEP_CONDITION:       "Step Condition",
 	}
 	if name, ok := names[t]; ok {
 		return name
@@ -1605,24 +1607,22 @@ func (c *ConsoleApp) setupCrawlJob() {
 		defer cancel()
 		c.stopFn = cancel
 
-		// handle stream
+		// handle stream: keep the entities of the data stream
+		var err error
 		if craw.Config.Stream {
-			stream := craw.GetDataStream()
-			go func() {
-				for d := range stream {
-					streamedData = append(streamedData, d)
+			err = craw.RunEach(ctx, c.runtimeVars, func(output string, entity any) error {
+				if output == "" {
+					streamedData = append(streamedData, entity)
 				}
-			}()
+				return nil
+			})
+		} else {
+			err = craw.Run(ctx, c.runtimeVars)
 		}
 
-		err := craw.Run(ctx, c.runtimeVars)
-
 		if err != nil {
 			c.appendLog("[red]" + escapeBrackets(err.Error()))
 		} else {
-			if craw.Config.Stream {
-				close(craw.GetDataStream())
-			}
 			c.appendLog("[green]Crawler run completed successfully")
 		}
 	}()
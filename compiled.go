@@ -117,8 +117,9 @@ func (c *CompiledJQ) RunArray(input any) ([]interface{}, error) {
 // Created at validation time to enable fail-fast and avoid runtime mutex contention.
 type CompiledTemplate struct {
 	Template   *template.Template
-	Source     string   // Original template string for error messages
-	UsedFields []string // Fields referenced in the template (for selective context)
+	Source     string       // Original template string for error messages
+	UsedFields []string     // Fields referenced in the template (for selective context)
+	Scope      ContextScope // Top-level context keys the template reads
 }
 
 // Execute renders the template with the given context.
@@ -224,6 +225,22 @@ func (c *CompiledBodyValue) Execute(ctx map[string]any) (any, error) {
 	return nil, nil
 }
 
+// addScope adds the context keys read by the templates of the value to scope
+func (c *CompiledBodyValue) addScope(scope *ContextScope) {
+	if c == nil {
+		return
+	}
+	if c.StringTemplate != nil {
+		scope.add(c.StringTemplate.Scope)
+	}
+	for _, v := range c.Map {
+		v.addScope(scope)
+	}
+	for _, v := range c.Array {
+		v.addScope(scope)
+	}
+}
+
 // CompiledStep holds all pre-compiled expressions for a single step.
 // This eliminates runtime compilation and its associated mutex contention.
 type CompiledStep struct {
@@ -233,6 +250,7 @@ type CompiledStep struct {
 	URLTemplate     *CompiledTemplate            // URL template
 	HeaderTemplates map[string]*CompiledTemplate // Header value templates
 	BodyTemplates   *CompiledBodyTemplates       // Body value templates
+	Pagination      *CompiledPagination          // Pagination expressions
 
 	// Transform and merge compilations
 	ResultTransformer *CompiledJQ    // Response transformation (.resultTransformer)
@@ -242,6 +260,19 @@ type CompiledStep struct {
 	PathExtractor  *CompiledJQ // Path extraction for forEach (.path)
 	SyntheticMerge *CompiledJQ // Default forEach merge: path + " = $new"
 
+	// Step condition (.when), evaluated before the step runs
+	When *CompiledJQ
+
+	// Values pushed to the data stream (.emit)
+	Emit *CompiledJQ
+
+	// Template context keys read by the step condition, the emit expression,
+	// and by the request templates, transformer and merge rule of the step
+	WhenScope     ContextScope
+	EmitScope     ContextScope
+	TemplateScope ContextScope
+	MergeScope    ContextScope // Template context keys read by the merge rule alone
+
 	// Nested steps (pre-compiled recursively)
 	NestedSteps []*CompiledStep
 }
@@ -254,6 +285,43 @@ func (cs *CompiledStep) ExecuteResultTransformer(input any, templateCtx map[stri
 	return cs.ResultTransformer.RunSingle(input, templateCtx)
 }
 
+// ExecuteWhen evaluates the step condition against the current context data.
+// Returns true when no condition is set or when the expression yields a value
+// other than false and null (jq truthiness).
+func (cs *CompiledStep) ExecuteWhen(data any, templateCtx map[string]any) (bool, error) {
+	if cs == nil || cs.When == nil {
+		return true, nil
+	}
+	result, err := cs.When.RunSingle(data, templateCtx)
+	if err != nil {
+		return false, err
+	}
+	return result != nil && result != false, nil
+}
+
+// ExecuteEmit evaluates the emit expression and returns every value it yields,
+// except null, so '.items[]' emits each item and 'select(...)' may emit nothing.
+func (cs *CompiledStep) ExecuteEmit(data any, templateCtx map[string]any) ([]any, error) {
+	if cs == nil || cs.Emit == nil {
+		return nil, nil
+	}
+	iter := cs.Emit.Code.Run(data, templateCtx)
+	var values []any
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, isErr := v.(error); isErr {
+			return nil, fmt.Errorf("jq error in '%s': %w", cs.Emit.Expression, err)
+		}
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
 // ExecutePathExtractor extracts items from context data for forEach iteration.
 func (cs *CompiledStep) ExecutePathExtractor(data any) ([]interface{}, error) {
 	if cs == nil || cs.PathExtractor == nil {
@@ -318,6 +386,9 @@ type CompiledConfig struct {
 	Steps                 map[string]*CompiledStep     // Pre-compiled steps keyed by step path
 	Topology              *StepTopology                // Step execution topology
 	GlobalHeaderTemplates map[string]*CompiledTemplate // Pre-compiled global header templates
+	Watermarks            []*CompiledJQ                // Pre-compiled incremental watermark selectors
+	GlobalHeaderScope     ContextScope                 // Template context keys read by the global headers
+	Emits                 bool                         // Some step emits values to the data stream
 }
 
 // GetCompiledStep retrieves a pre-compiled step by its path.
@@ -402,6 +473,7 @@ func compileTemplate(source string) (*CompiledTemplate, error) {
 		Template:   tmpl,
 		Source:     source,
 		UsedFields: extractTemplateFields(source),
+		Scope:      templateScope(tmpl),
 	}, nil
 }
 
@@ -487,6 +559,7 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 		}
 		if cs.URLTemplate != nil {
 			allFields = append(allFields, cs.URLTemplate.UsedFields...)
+			cs.TemplateScope.add(cs.URLTemplate.Scope)
 		}
 
 		// Header templates
@@ -500,10 +573,17 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 				if tmpl != nil {
 					cs.HeaderTemplates[k] = tmpl
 					allFields = append(allFields, tmpl.UsedFields...)
+					cs.TemplateScope.add(tmpl.Scope)
 				}
 			}
 		}
 
+		// Pagination selectors, increments, templates and stop conditions
+		cs.Pagination, err = compilePagination(step.Request.Pagination)
+		if err != nil {
+			return nil, nil, fmt.Errorf("pagination: %w", err)
+		}
+
 		// Body templates
 		if len(step.Request.Body) > 0 {
 			cs.BodyTemplates = &CompiledBodyTemplates{
@@ -516,6 +596,7 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 				}
 				cs.BodyTemplates.Templates[k] = cv
 				allFields = append(allFields, fields...)
+				cv.addScope(&cs.TemplateScope)
 			}
 		}
 	}
@@ -527,6 +608,7 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 			return nil, nil, fmt.Errorf("resultTransformer: %w", err)
 		}
 		allFields = append(allFields, cs.ResultTransformer.UsedPaths...)
+		cs.TemplateScope.add(jqContextScope(step.ResultTransformer))
 	}
 
 	// Compile merge rule (unified from mergeOn/mergeWithParentOn/mergeWithContext)
@@ -566,6 +648,11 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 		allFields = append(allFields, rule.UsedPaths...)
 	}
 
+	if cs.Merge != nil {
+		cs.MergeScope = jqContextScope(cs.Merge.SourceRule)
+		cs.TemplateScope.add(cs.MergeScope)
+	}
+
 	// Compile forEach path extractor and synthetic merge
 	if step.Path != "" {
 		cs.PathExtractor, err = compileJQ(step.Path)
@@ -582,6 +669,26 @@ func CompileStep(step *Step, stepPath string) (*CompiledStep, []string, error) {
 		}
 	}
 
+	// Compile step condition
+	if step.When != "" {
+		cs.When, err = compileJQ(step.When, JQ_CTX_KEY)
+		if err != nil {
+			return nil, nil, fmt.Errorf("when: %w", err)
+		}
+		allFields = append(allFields, cs.When.UsedPaths...)
+		cs.WhenScope = jqContextScope(step.When)
+	}
+
+	// Compile emit expression
+	if step.Emit != "" {
+		cs.Emit, err = compileJQ(step.Emit, JQ_CTX_KEY)
+		if err != nil {
+			return nil, nil, fmt.Errorf("emit: %w", err)
+		}
+		allFields = append(allFields, cs.Emit.UsedPaths...)
+		cs.EmitScope = jqContextScope(step.Emit)
+	}
+
 	// Compile nested steps recursively
 	if len(step.Steps) > 0 {
 		cs.NestedSteps = make([]*CompiledStep, len(step.Steps))
@@ -617,10 +724,18 @@ func CompileConfig(cfg Config) (*CompiledConfig, error) {
 			}
 			if tmpl != nil {
 				cc.GlobalHeaderTemplates[k] = tmpl
+				cc.GlobalHeaderScope.add(tmpl.Scope)
 			}
 		}
 	}
 
+	// Compile incremental watermark selectors
+	watermarks, err := compileWatermarks(cfg.Incremental)
+	if err != nil {
+		return nil, fmt.Errorf("incremental: %w", err)
+	}
+	cc.Watermarks = watermarks
+
 	// Compile all top-level steps
 	for i, step := range cfg.Steps {
 		stepPath := fmt.Sprintf("steps[%d]", i)
@@ -636,6 +751,13 @@ func CompileConfig(cfg Config) (*CompiledConfig, error) {
 		storeNestedSteps(cc.Steps, compiled)
 	}
 
+	for _, compiled := range cc.Steps {
+		if compiled.Emit != nil {
+			cc.Emits = true
+			break
+		}
+	}
+
 	return cc, nil
 }
 
@@ -527,6 +527,52 @@ func TestCompileConfig_InvalidJQ(t *testing.T) {
 	assert.Nil(t, compiled)
 }
 
+// TestCompileStep_When tests that step conditions compile and evaluate with jq truthiness
+func TestCompileStep_When(t *testing.T) {
+	step := &Step{
+		Type: "request",
+		Request: &RequestConfig{
+			URL:    "https://api.example.com",
+			Method: "GET",
+		},
+		When: ".active and $ctx.mode == \"full\"",
+	}
+
+	compiled, _, err := CompileStep(step, "steps[0]")
+	require.NoError(t, err)
+	require.NotNil(t, compiled.When)
+
+	run, err := compiled.ExecuteWhen(map[string]any{"active": true}, map[string]any{"mode": "full"})
+	require.NoError(t, err)
+	assert.True(t, run)
+
+	run, err = compiled.ExecuteWhen(map[string]any{"active": true}, map[string]any{"mode": "delta"})
+	require.NoError(t, err)
+	assert.False(t, run)
+
+	// null is falsy, any other value is truthy
+	nullStep := &Step{Type: "forEach", Path: ".", As: "x", When: ".missing"}
+	compiled, _, err = CompileStep(nullStep, "steps[0]")
+	require.NoError(t, err)
+	run, err = compiled.ExecuteWhen(map[string]any{}, map[string]any{})
+	require.NoError(t, err)
+	assert.False(t, run)
+	run, err = compiled.ExecuteWhen(map[string]any{"missing": 0}, map[string]any{})
+	require.NoError(t, err)
+	assert.True(t, run)
+
+	// No condition always runs
+	var noCondition *CompiledStep
+	run, err = noCondition.ExecuteWhen(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, run)
+
+	// Syntax errors are caught at compile time
+	_, _, err = CompileStep(&Step{Type: "forEach", Path: ".", As: "x", When: ".a ==="}, "steps[0]")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "when")
+}
+
 // TestCompileConfig_InvalidTemplate tests that invalid templates fail at compile time
 func TestCompileConfig_InvalidTemplate(t *testing.T) {
 	cfg := Config{
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import "sort"
+
+// contextChain is the immutable set of contexts visible to a step, stored as
+// a parent-linked list. Entering an iteration or a page adds a single link
+// instead of copying every context, and iterations share their parent's links.
+// Lookups start from the newest link, so a key bound further down the step
+// tree shadows the same key bound above, like overwriting a map entry.
+type contextChain struct {
+	parent *contextChain
+	key    string
+	ctx    *Context
+}
+
+// newContextChain creates a chain holding the contexts of m
+func newContextChain(m map[string]*Context) *contextChain {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var chain *contextChain
+	for _, k := range keys {
+		chain = chain.with(k, m[k])
+	}
+	return chain
+}
+
+// with returns a chain that also binds key to ctx. The receiver is unchanged.
+func (c *contextChain) with(key string, ctx *Context) *contextChain {
+	return &contextChain{parent: c, key: key, ctx: ctx}
+}
+
+// get returns the context bound to key
+func (c *contextChain) get(key string) (*Context, bool) {
+	for link := c; link != nil; link = link.parent {
+		if link.key == key {
+			return link.ctx, true
+		}
+	}
+	return nil, false
+}
+
+// each calls fn once for every visible key, newest binding first
+func (c *contextChain) each(fn func(key string, ctx *Context)) {
+	seen := make(map[string]struct{})
+	for link := c; link != nil; link = link.parent {
+		if _, shadowed := seen[link.key]; shadowed {
+			continue
+		}
+		seen[link.key] = struct{}{}
+		fn(link.key, link.ctx)
+	}
+}
+
+// toMap returns the visible contexts as a map, for the profiler
+func (c *contextChain) toMap() map[string]*Context {
+	m := make(map[string]*Context)
+	c.each(func(key string, ctx *Context) {
+		m[key] = ctx
+	})
+	return m
+}
+
+// size returns the number of links, shadowed bindings included
+func (c *contextChain) size() int {
+	n := 0
+	for link := c; link != nil; link = link.parent {
+		n++
+	}
+	return n
+}
+
+// position returns the position of the newest link bound to ctx, counted from
+// the oldest link, or -1 if ctx is not in the chain. A chain extended with
+// keeps the positions of its links, so a position taken in a nested step
+// identifies the same context in the steps above it.
+func (c *contextChain) position(ctx *Context) int {
+	n := c.size()
+	for link := c; link != nil; link = link.parent {
+		n--
+		if link.ctx == ctx {
+			return n
+		}
+	}
+	return -1
+}
+
+// at returns the context of the link at position pos (see position)
+func (c *contextChain) at(pos int) (*Context, bool) {
+	skip := c.size() - 1 - pos
+	if pos < 0 || skip < 0 {
+		return nil, false
+	}
+	link := c
+	for ; skip > 0; skip-- {
+		link = link.parent
+	}
+	return link.ctx, true
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextChain(t *testing.T) {
+	root := &Context{Data: map[string]any{}, key: "root"}
+	base := newContextChain(map[string]*Context{"root": root})
+
+	item1 := &Context{Data: 1, ParentContext: "root", key: "item", depth: 1}
+	item2 := &Context{Data: 2, ParentContext: "item", key: "item", depth: 2}
+	outer := base.with("item", item1)
+	inner := outer.with("item", item2)
+
+	got, ok := inner.get("item")
+	require.True(t, ok)
+	assert.Same(t, item2, got, "the newest binding shadows the outer one")
+	got, ok = outer.get("item")
+	require.True(t, ok)
+	assert.Same(t, item1, got, "binding a key leaves the parent chain unchanged")
+	_, ok = base.get("item")
+	assert.False(t, ok)
+	got, ok = inner.get("root")
+	require.True(t, ok)
+	assert.Same(t, root, got)
+
+	var keys []string
+	inner.each(func(key string, ctx *Context) {
+		keys = append(keys, key)
+	})
+	assert.Equal(t, []string{"item", "root"}, keys, "shadowed bindings are skipped")
+	assert.Equal(t, map[string]*Context{"item": item2, "root": root}, inner.toMap())
+
+	var empty *contextChain
+	_, ok = empty.get("root")
+	assert.False(t, ok)
+	assert.Empty(t, empty.toMap())
+}
+
+func TestContextChainPosition(t *testing.T) {
+	root := &Context{Data: map[string]any{}, key: "root"}
+	base := newContextChain(map[string]*Context{"root": root})
+	item1 := &Context{Data: 1, key: "item"}
+	item2 := &Context{Data: 2, key: "item"}
+	inner := base.with("item", item1).with("item", item2)
+
+	assert.Equal(t, 3, inner.size(), "shadowed links are counted")
+	assert.Equal(t, 0, inner.position(root))
+	assert.Equal(t, 1, inner.position(item1))
+	assert.Equal(t, 2, inner.position(item2))
+	assert.Equal(t, -1, base.position(item1))
+
+	// A position taken in a nested chain points to the same context above it
+	got, ok := base.at(inner.position(root))
+	require.True(t, ok)
+	assert.Same(t, root, got)
+	got, ok = inner.at(1)
+	require.True(t, ok)
+	assert.Same(t, item1, got)
+	_, ok = base.at(1)
+	assert.False(t, ok)
+	_, ok = base.at(-1)
+	assert.False(t, ok)
+}
+
+func TestWorkingContextKeys(t *testing.T) {
+	root := &Context{Data: map[string]any{}, key: "root"}
+	canonical := map[string]*Context{"root": root}
+	contexts := newContextChain(canonical)
+
+	contexts, working := withWorkingContext(contexts, root, []any{1}, canonical)
+	assert.Equal(t, "_response_root", working.key, "a canonical context is not shadowed")
+	got, _ := contexts.get("root")
+	assert.Same(t, root, got)
+
+	contexts, item := withChildContext(contexts, working, "item", map[string]any{"id": 1})
+	_, nested := withWorkingContext(contexts, item, map[string]any{"id": 2}, canonical)
+	assert.Equal(t, "item", nested.key, "an iteration context is replaced by the response")
+	assert.Equal(t, "_response_root", nested.ParentContext)
+}
+
+var (
+	chainSink *contextChain
+	mapSink   map[string]*Context
+)
+
+// BenchmarkContextChain compares binding an iteration context on a chain with
+// copying a context map of the same size, as done before the chain existed
+func BenchmarkContextChain(b *testing.B) {
+	for _, size := range []int{4, 16, 64} {
+		contexts := make(map[string]*Context, size)
+		for i := range size {
+			contexts[fmt.Sprintf("ctx%d", i)] = &Context{key: fmt.Sprintf("ctx%d", i)}
+		}
+		current := contexts["ctx0"]
+
+		b.Run(fmt.Sprintf("chain/%d", size), func(b *testing.B) {
+			chain := newContextChain(contexts)
+			b.ReportAllocs()
+			for b.Loop() {
+				chainSink, _ = withChildContext(chain, current, "item", nil)
+			}
+		})
+		b.Run(fmt.Sprintf("mapcopy/%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				m := make(map[string]*Context, len(contexts)+1)
+				for k, v := range contexts {
+					m[k] = v
+				}
+				m["item"] = &Context{ParentContext: current.key, key: "item", depth: current.depth + 1}
+				mapSink = m
+			}
+		})
+	}
+}
+
+// BenchmarkCrawlerFixtures runs nested crawler_test fixtures end to end
+func BenchmarkCrawlerFixtures(b *testing.B) {
+	fixtures := []struct {
+		name   string
+		config string
+		mocks  map[string]string
+	}{
+		{"foreach_value", "testdata/crawler/example_foreach_value.yaml", map[string]string{
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=1": "testdata/crawler/example_foreach_value/facilities_1.json",
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=2": "testdata/crawler/example_foreach_value/facilities_2.json",
+		}},
+		{"pagination_increment_nested", "testdata/crawler/example_pagination_increment_nested.yaml", map[string]string{
+			"https://www.onecenter.info/api/DAZ/GetFacilities?offset=0":          "testdata/crawler/paginated_increment_stream/facilities_1.json",
+			"https://www.onecenter.info/api/DAZ/GetFacilities?offset=1":          "testdata/crawler/paginated_increment_stream/facilities_2.json",
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=1": "testdata/crawler/paginated_increment_stream/facility_id_1.json",
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=2": "testdata/crawler/paginated_increment_stream/facility_id_2.json",
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=3": "testdata/crawler/paginated_increment_stream/facility_id_3.json",
+			"https://www.onecenter.info/api/DAZ/FacilityFreePlaces?FacilityID=4": "testdata/crawler/paginated_increment_stream/facility_id_4.json",
+		}},
+		{"forvalues_nested", "testdata/crawler/forvalues_nested.yaml", map[string]string{
+			"https://api.example.com/config?region=eu&env=prod":    "testdata/crawler/forvalues_nested/config_eu_prod.json",
+			"https://api.example.com/config?region=eu&env=staging": "testdata/crawler/forvalues_nested/config_eu_staging.json",
+			"https://api.example.com/config?region=us&env=prod":    "testdata/crawler/forvalues_nested/config_us_prod.json",
+			"https://api.example.com/config?region=us&env=staging": "testdata/crawler/forvalues_nested/config_us_staging.json",
+		}},
+		{"deep_nesting", "testdata/crawler/edge_case_deep_nesting.yaml", map[string]string{
+			"https://api.example.com/level3?l1=L1&l2=L2": "testdata/crawler/edge_case_deep_nesting/level3.json",
+		}},
+	}
+
+	for _, fixture := range fixtures {
+		b.Run(fixture.name, func(b *testing.B) {
+			craw, _, err := NewApiCrawler(fixture.config)
+			require.Nil(b, err)
+			craw.SetClient(&http.Client{Transport: crawler_testing.NewMockRoundTripper(fixture.mocks)})
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := craw.Run(context.TODO(), nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
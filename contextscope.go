@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"regexp"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// ContextScope is the part of the template context an expression reads: the
+// top-level keys it references, or the whole context when All is set because
+// the references cannot be determined statically (e.g. {{ . }} or $ctx | keys).
+// The zero value reads nothing.
+type ContextScope struct {
+	All  bool
+	Keys []string // sorted, without duplicates
+}
+
+// fullScope reads the whole template context
+var fullScope = &ContextScope{All: true}
+
+// add extends the scope with the keys of other
+func (s *ContextScope) add(other ContextScope) {
+	if s.All {
+		return
+	}
+	if other.All {
+		s.All, s.Keys = true, nil
+		return
+	}
+	for _, key := range other.Keys {
+		s.addKey(key)
+	}
+}
+
+// addKey adds a single top-level key
+func (s *ContextScope) addKey(key string) {
+	i := sort.SearchStrings(s.Keys, key)
+	if i < len(s.Keys) && s.Keys[i] == key {
+		return
+	}
+	s.Keys = append(s.Keys, "")
+	copy(s.Keys[i+1:], s.Keys[i:])
+	s.Keys[i] = key
+}
+
+// templateScope returns the top-level keys read by a template. Fields are
+// collected wherever they appear, also inside range and with, so the scope
+// may contain keys that are only read relative to another value; any use of
+// the context as a whole (., $ or a nested template call) reads everything.
+func templateScope(tmpl *template.Template) ContextScope {
+	var scope ContextScope
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		if scope.All || node == nil {
+			return
+		}
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.FieldNode:
+			scope.addKey(n.Ident[0])
+		case *parse.VariableNode:
+			if n.Ident[0] != "$" {
+				return
+			}
+			if len(n.Ident) == 1 {
+				scope.All = true
+				return
+			}
+			scope.addKey(n.Ident[1])
+		case *parse.DotNode, *parse.TemplateNode:
+			scope.All = true
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	if scope.All {
+		scope.Keys = nil
+	}
+	return scope
+}
+
+// ctxVariablePattern matches every use of $ctx, capturing the key when it is
+// directly followed by a field access
+var ctxVariablePattern = regexp.MustCompile(`\$ctx\b(?:\.([a-zA-Z_][a-zA-Z0-9_]*))?`)
+
+// jqContextScope returns the top-level keys of $ctx read by a jq expression.
+// Only $ctx.<key> accesses can be followed; any other use of $ctx (piping it,
+// indexing it with brackets or binding it to another variable) reads everything.
+func jqContextScope(expr string) ContextScope {
+	var scope ContextScope
+	for _, match := range ctxVariablePattern.FindAllStringSubmatch(expr, -1) {
+		if match[1] == "" {
+			return ContextScope{All: true}
+		}
+		scope.addKey(match[1])
+	}
+	return scope
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateScope(t *testing.T) {
+	tests := []struct {
+		source string
+		want   ContextScope
+	}{
+		{"https://api.example.com/items/{{ .item.id }}", ContextScope{Keys: []string{"item"}}},
+		{"{{ add .offset 1 }}-{{ .limit | int }}", ContextScope{Keys: []string{"limit", "offset"}}},
+		{"{{ if .a }}{{ .b }}{{ else }}{{ .c }}{{ end }}", ContextScope{Keys: []string{"a", "b", "c"}}},
+		{"{{ range .items }}{{ .id }},{{ end }}", ContextScope{Keys: []string{"id", "items"}}},
+		{"{{ with .item }}{{ $.token }}{{ end }}", ContextScope{Keys: []string{"item", "token"}}},
+		{"{{ $x := .a }}{{ $x.b }}", ContextScope{Keys: []string{"a"}}},
+		{"{{ toJson . }}", ContextScope{All: true}},
+		{"{{ range .items }}{{ . }}{{ end }}", ContextScope{All: true}},
+		{"{{ get $ \"item\" }}", ContextScope{All: true}},
+		{`{{ define "x" }}{{ .secret }}{{ end }}{{ template "x" .item }}`, ContextScope{All: true}},
+	}
+	for _, tt := range tests {
+		compiled, err := compileTemplate(tt.source)
+		require.NoError(t, err, tt.source)
+		assert.Equal(t, tt.want, compiled.Scope, tt.source)
+	}
+}
+
+func TestJQContextScope(t *testing.T) {
+	assert.Equal(t, ContextScope{}, jqContextScope(".details = $res"))
+	assert.Equal(t, ContextScope{Keys: []string{"item", "region"}}, jqContextScope(".[$ctx.region] = $res | .id = $ctx.item.id"))
+	assert.Equal(t, ContextScope{All: true}, jqContextScope("$ctx | keys"))
+	assert.Equal(t, ContextScope{All: true}, jqContextScope(`.x = $ctx["item"]`))
+	assert.Equal(t, ContextScope{All: true}, jqContextScope("$ctx.item as $i | $ctx as $c | $c.item"))
+}
+
+func TestScopedTemplateContextMatchesFullContext(t *testing.T) {
+	craw := &ApiCrawler{}
+	contextMap := map[string]*Context{
+		"root":          {Data: map[string]any{"a": float64(1), "b": float64(2), "item": "shadowed", "big": []any{"x", "y"}}},
+		"item":          {Data: map[string]any{"id": float64(5)}},
+		"_response_abc": {Data: map[string]any{"b": 3.5}},
+	}
+	vars := map[string]any{"c": 4, "a": "var wins"}
+
+	full := craw.contextMapToTemplate(newContextChain(contextMap), vars, nil)
+	scoped := craw.contextMapToTemplate(newContextChain(contextMap), vars, &ContextScope{Keys: []string{"a", "b", "c", "item", "missing"}})
+
+	assert.Equal(t, map[string]any{
+		"a":    full["a"],
+		"b":    full["b"],
+		"c":    full["c"],
+		"item": full["item"],
+	}, scoped)
+	assert.Equal(t, "var wins", scoped["a"])
+	assert.Equal(t, "3.5", scoped["b"], "_response_ data wins over root data")
+	assert.Equal(t, map[string]any{"id": int64(5)}, scoped["item"], "contexts win over root data")
+
+	scoped["item"].(map[string]any)["id"] = 6
+	assert.Equal(t, float64(5), contextMap["item"].Data.(map[string]any)["id"], "the scoped context is a copy")
+
+	assert.Empty(t, craw.contextMapToTemplate(newContextChain(contextMap), vars, &ContextScope{}))
+}
+
+func TestScopedContextThroughCrawl(t *testing.T) {
+	configContent := `
+rootContext:
+  region: eu
+  history: [1, 2, 3]
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/{{ .region }}/items
+      method: GET
+    resultTransformer: '.'
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            when: (.skip | not) and $ctx.root.region == "eu"
+            request:
+              url: https://api.example.com/{{ .region }}/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res | .region = $ctx.root.region
+`
+	var paths []string
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		paths = append(paths, req.URL.Path)
+		if req.URL.Path != "/eu/items" {
+			return `{"owner": "a"}`
+		}
+		return `[{"id": 1}, {"id": 2, "skip": true}]`
+	}))
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []string{"/eu/items", "/eu/items/1"}, paths)
+	out, err := json.Marshal(craw.GetData())
+	require.Nil(t, err)
+	assert.JSONEq(t, `[{"id": 1, "details": {"owner": "a"}, "region": "eu"}, {"id": 2, "skip": true}]`, string(out))
+}
+
+func TestNewestResponseFieldWins(t *testing.T) {
+	craw := &ApiCrawler{}
+	older := &Context{Data: map[string]any{"token": "outer", "page": float64(1)}, key: "_response_root"}
+	newer := &Context{Data: map[string]any{"token": "inner"}, ParentContext: "root", key: "_response_item", depth: 1}
+	contexts := newContextChain(map[string]*Context{"root": {Data: map[string]any{}, key: "root"}}).
+		with("_response_root", older).
+		with("_response_item", newer)
+
+	full := craw.contextMapToTemplate(contexts, nil, nil)
+	scoped := craw.contextMapToTemplate(contexts, nil, &ContextScope{Keys: []string{"token", "page"}})
+	assert.Equal(t, "inner", full["token"], "the newest working context wins in the full context")
+	assert.Equal(t, "inner", scoped["token"], "the newest working context wins in the scoped context")
+	assert.Equal(t, full["page"], scoped["page"])
+}
+
+func TestNestedRequestsSameField(t *testing.T) {
+	configContent := `
+rootContext: {}
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/outer
+      method: GET
+    noopMerge: true
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/middle?token={{ .token }}
+          method: GET
+        noopMerge: true
+        steps:
+          - type: request
+            request:
+              url: https://api.example.com/leaf?token={{ .token }}
+              method: GET
+            noopMerge: true
+`
+	for _, profiling := range []bool{false, true} {
+		var queries []string
+		craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+			queries = append(queries, req.URL.Path+"?"+req.URL.RawQuery)
+			if req.URL.Path == "/outer" {
+				return `{"token": "outer"}`
+			}
+			return `{"token": "inner"}`
+		}))
+		if profiling {
+			// The profiler builds the full template context instead of the scoped one
+			profiler := craw.EnableProfiler()
+			go func() {
+				for range profiler {
+				}
+			}()
+			defer close(profiler)
+		}
+		require.Nil(t, craw.Run(context.TODO(), nil))
+
+		assert.Equal(t, []string{"/outer?", "/middle?token=outer", "/leaf?token=inner"}, queries,
+			"the response of the nested request shadows the outer one (profiling: %v)", profiling)
+	}
+}
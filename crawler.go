@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -15,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,14 +43,27 @@ type ParallelismConfig struct {
 	MaxConcurrency    int     `yaml:"maxConcurrency,omitempty" json:"maxConcurrency,omitempty"`
 	RequestsPerSecond float64 `yaml:"requestsPerSecond,omitempty" json:"requestsPerSecond,omitempty"`
 	Burst             int     `yaml:"burst,omitempty" json:"burst,omitempty"`
+	ErrorMode         string  `yaml:"errorMode,omitempty" json:"errorMode,omitempty"` // failFast (default) or collectErrors
 }
 
+// Supported values for ParallelismConfig.ErrorMode
+const (
+	ParallelErrorModeFailFast      = "failFast"      // cancel the remaining iterations on the first error
+	ParallelErrorModeCollectErrors = "collectErrors" // run every iteration and return all errors joined
+)
+
 type Config struct {
 	Steps          []Step               `yaml:"steps" json:"steps"`
 	RootContext    interface{}          `yaml:"rootContext" json:"rootContext"`
 	Authentication *AuthenticatorConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 	Headers        map[string]string    `yaml:"headers,omitempty" json:"headers,omitempty"`
 	Stream         bool                 `yaml:"stream,omitempty" json:"stream,omitempty"`
+	Retry          *RetryConfig         `yaml:"retry,omitempty" json:"retry,omitempty"` // default retry policy for all request steps
+	Checkpoint     *CheckpointConfig    `yaml:"checkpoint,omitempty" json:"checkpoint,omitempty"`
+	Incremental    *IncrementalConfig   `yaml:"incremental,omitempty" json:"incremental,omitempty"`
+	RateLimits     *RateLimitsConfig    `yaml:"rateLimits,omitempty" json:"rateLimits,omitempty"` // limits shared by all requests, including auth
+	NumberMode     string               `yaml:"numberMode,omitempty" json:"numberMode,omitempty"` // exact (default) or float
+	Outputs        []string             `yaml:"outputs,omitempty" json:"outputs,omitempty"`       // named streams steps can stream into, besides the data stream
 }
 
 type Step struct {
@@ -65,6 +80,10 @@ type Step struct {
 	MergeWithContext  *MergeWithContextRule `yaml:"mergeWithContext,omitempty" json:"mergeWithContext,omitempty"`
 	NoopMerge         bool                  `yaml:"noopMerge,omitempty" json:"noopMerge,omitempty"`
 	Parallelism       *ParallelismConfig    `yaml:"parallelism,omitempty" json:"parallelism,omitempty"`
+	When              string                `yaml:"when,omitempty" json:"when,omitempty"`       // jq predicate; the step is skipped unless it yields a truthy value
+	OnError           string                `yaml:"onError,omitempty" json:"onError,omitempty"` // forEach/forValues: fail (default), skip or collect failed iterations
+	Emit              string                `yaml:"emit,omitempty" json:"emit,omitempty"`       // jq expression whose values are pushed to the data stream (stream mode)
+	Output            string                `yaml:"output,omitempty" json:"output,omitempty"`   // named output the step streams into (default: the data stream)
 }
 
 type RequestConfig struct {
@@ -74,6 +93,10 @@ type RequestConfig struct {
 	Body           map[string]any       `yaml:"body,omitempty" json:"body,omitempty"`
 	Pagination     Pagination           `yaml:"pagination,omitempty" json:"pagination,omitempty"`
 	Authentication *AuthenticatorConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Retry          *RetryConfig         `yaml:"retry,omitempty" json:"retry,omitempty"`                   // overrides Config.Retry
+	ExpectStatus   []int                `yaml:"expectStatus,omitempty" json:"expectStatus,omitempty"`     // accepted status codes (default: any 2xx)
+	OnStatus       map[string]string    `yaml:"onStatus,omitempty" json:"onStatus,omitempty"`             // status code or class ("4xx") => fail|skip|empty
+	ResponseFormat string               `yaml:"responseFormat,omitempty" json:"responseFormat,omitempty"` // json (default), xml, csv, ndjson, text or auto
 }
 
 type MergeWithContextRule struct {
@@ -94,8 +117,13 @@ type stepExecution struct {
 	compiledStep      *CompiledStep // Pre-compiled expressions for this step (nil if not available)
 	currentContextKey string
 	currentContext    *Context
-	contextMap        map[string]*Context
-	parentID          string // Parent step ID for profiler hierarchy
+	contexts          *contextChain // Contexts visible to the step, by key
+	parentID          string        // Parent step ID for profiler hierarchy
+
+	scope              string           // Checkpoint scope: indices of the enclosing iterations/pages
+	checkpointDisabled bool             // Set below parallel steps, whose iterations are not checkpointed individually
+	checkpointChain    int              // Contexts visible to the step whose iteration or page encloses this one
+	resumed            *checkpointEntry // Progress saved by a previous run (nil if none)
 }
 
 // httpRequestContext encapsulates HTTP request preparation parameters
@@ -108,13 +136,14 @@ type httpRequestContext struct {
 	bodyParams     map[string]interface{}
 	contentType    string
 	queryParams    map[string]string
+	headerParams   map[string]string // pagination params with location: header
 	nextPageURL    string
 	authenticator  Authenticator
 	compiledStep   *CompiledStep // Pre-compiled templates (nil for fallback)
 }
 
-// forEachResult holds the result of a single forEach iteration
-type forEachResult struct {
+// iterationResult holds the result of a single forEach/forValues iteration
+type iterationResult struct {
 	index          int
 	result         any
 	profilerEvents []StepProfilerData
@@ -128,11 +157,21 @@ type ApiCrawler struct {
 	ContextMap          map[string]*Context
 	globalAuthenticator Authenticator
 	DataStream          chan any
-	runVars             map[string]any // Runtime variables injected at execution time
+	outputStreams       map[string]chan any // Named output streams (Config.Outputs), by name
+	sink                chan streamItem     // Receives every streamed entity during RunEach (nil otherwise)
+	runVars             map[string]any      // Runtime variables injected at execution time
 	logger              Logger
 	httpClient          HTTPClient
+	client              HTTPClient      // httpClient wrapped with the rate limits, used during Run
+	requestLimiter      *requestLimiter // Global rate limits (nil when not configured)
 	profiler            *Profiler
 	mergeMutex          sync.Mutex // Protects concurrent merge operations
+	checkpointStore     CheckpointStore
+	checkpoint          *checkpointTracker // Progress of the current Run (nil when checkpointing is disabled)
+	stateStore          StateStore
+	watermarks          *watermarkTracker // Watermarks of the current Run (nil when not incremental)
+	deadLetters         []DeadLetter      // Iterations collected by onError: collect during the current Run
+	deadLetterMutex     sync.Mutex
 }
 
 func NewApiCrawler(configPath string) (*ApiCrawler, []ValidationError, error) {
@@ -165,11 +204,24 @@ func NewApiCrawler(configPath string) (*ApiCrawler, []ValidationError, error) {
 		ContextMap:     map[string]*Context{},
 		logger:         NewNoopLogger(),
 		profiler:       nil,
+		requestLimiter: newRequestLimiter(cfg.RateLimits),
 	}
 
-	// handle stream channel
+	// handle stream channels
 	if cfg.Stream {
 		c.DataStream = make(chan any)
+		c.outputStreams = make(map[string]chan any, len(cfg.Outputs))
+		for _, name := range cfg.Outputs {
+			c.outputStreams[name] = make(chan any)
+		}
+	}
+
+	if cfg.Checkpoint != nil && cfg.Checkpoint.Path != "" {
+		c.checkpointStore = NewFileCheckpointStore(cfg.Checkpoint.Path)
+	}
+
+	if cfg.Incremental != nil && cfg.Incremental.StateFile != "" {
+		c.stateStore = NewFileStateStore(cfg.Incremental.StateFile)
 	}
 
 	return c, nil, nil
@@ -179,6 +231,17 @@ func (a *ApiCrawler) GetDataStream() chan interface{} {
 	return a.DataStream
 }
 
+// GetOutputStream returns the stream of a named output declared in
+// Config.Outputs ("" is the data stream), or nil if there is none.
+// Like the data stream, every output must be consumed while the crawler runs,
+// and is closed by the consumer once Run returns.
+func (a *ApiCrawler) GetOutputStream(name string) chan interface{} {
+	if name == "" {
+		return a.DataStream
+	}
+	return a.outputStreams[name]
+}
+
 func (a *ApiCrawler) GetData() interface{} {
 	return a.ContextMap["root"].Data
 }
@@ -191,6 +254,18 @@ func (a *ApiCrawler) SetClient(client HTTPClient) {
 	a.httpClient = client
 }
 
+// SetCheckpointStore sets the store used to save and resume crawl progress,
+// replacing the file store configured by checkpoint.path. nil disables checkpointing.
+func (a *ApiCrawler) SetCheckpointStore(store CheckpointStore) {
+	a.checkpointStore = store
+}
+
+// SetStateStore sets the store holding the incremental watermarks, replacing
+// the file store configured by incremental.stateFile. nil disables incremental crawling.
+func (a *ApiCrawler) SetStateStore(store StateStore) {
+	a.stateStore = store
+}
+
 func (a *ApiCrawler) EnableProfiler() chan StepProfilerData {
 	a.profiler = NewProfiler(&a.mergeMutex)
 	return a.profiler.Channel()
@@ -205,22 +280,76 @@ func (a *ApiCrawler) getCompiledStep(stepPath string) *CompiledStep {
 	return a.CompiledConfig.GetCompiledStep(stepPath)
 }
 
-func (c *ApiCrawler) newStepExecution(step Step, stepPath string, currentContextKey string, contextMap map[string]*Context, parentID string) *stepExecution {
+func (c *ApiCrawler) newStepExecution(step Step, stepPath string, currentContextKey string, contexts *contextChain, parentID string) *stepExecution {
+	currentContext, _ := contexts.get(currentContextKey)
 	return &stepExecution{
 		step:              step,
 		stepPath:          stepPath,
 		compiledStep:      c.getCompiledStep(stepPath),
 		currentContextKey: currentContextKey,
-		contextMap:        contextMap,
-		currentContext:    contextMap[currentContextKey],
+		contexts:          contexts,
+		currentContext:    currentContext,
 		parentID:          parentID,
+		checkpointChain:   contexts.size(),
 	}
 }
 
+// newNestedExecution creates the execution of a step nested in iteration (or page) index
+// of parent. Nested steps inherit the checkpoint scope; checkpointing stops below parallel steps.
+func (c *ApiCrawler) newNestedExecution(parent *stepExecution, index int, step Step, stepPath string, currentContextKey string, contexts *contextChain, parentID string) *stepExecution {
+	exec := c.newStepExecution(step, stepPath, currentContextKey, contexts, parentID)
+	exec.scope = nestedScope(parent.scope, index)
+	exec.checkpointDisabled = parent.checkpointDisabled || parent.step.Parallelism != nil
+	exec.checkpointChain = parent.contexts.size()
+	if parent.checkpointDisabled {
+		exec.checkpointChain = parent.checkpointChain
+	}
+	return exec
+}
+
 func (c *ApiCrawler) Run(ctx context.Context, vars map[string]any) error {
+	// Load the checkpoint of an interrupted run, if any
+	c.checkpoint = nil
+	if c.checkpointStore != nil {
+		interval := 0
+		if c.Config.Checkpoint != nil {
+			interval = c.Config.Checkpoint.Interval
+		}
+		tracker, err := newCheckpointTracker(c.checkpointStore, interval, &c.mergeMutex)
+		if err != nil {
+			return err
+		}
+		if tracker.Resuming() {
+			c.logger.Info("[Checkpoint] Resuming from saved checkpoint")
+		}
+		c.checkpoint = tracker
+	}
+
+	// Load the watermarks saved by the last successful run
+	c.watermarks = nil
+	if c.Config.Incremental != nil && c.stateStore != nil {
+		var selectors []*CompiledJQ
+		if c.CompiledConfig != nil {
+			selectors = c.CompiledConfig.Watermarks
+		} else {
+			var err error
+			if selectors, err = compileWatermarks(c.Config.Incremental); err != nil {
+				return err
+			}
+		}
+		tracker, err := newWatermarkTracker(c.Config.Incremental, selectors, c.stateStore)
+		if err != nil {
+			return err
+		}
+		c.watermarks = tracker
+	}
+
+	// All requests, including authentication, go through the global rate limits
+	c.client = c.requestLimiter.wrap(c.httpClient)
+
 	// instantiate global authenticator at Run time so we force the authenticator to refresh
 	if c.Config.Authentication != nil {
-		c.globalAuthenticator = NewAuthenticator(*c.Config.Authentication, c.httpClient)
+		c.globalAuthenticator = NewAuthenticator(*c.Config.Authentication, c.client)
 	} else {
 		c.globalAuthenticator = NoopAuthenticator{
 			BaseAuthenticator: &BaseAuthenticator{
@@ -239,21 +368,41 @@ func (c *ApiCrawler) Run(ctx context.Context, vars map[string]any) error {
 	c.ContextMap["root"] = rootCtx
 	currentContext := "root"
 
-	c.runVars = vars
+	c.runVars = c.watermarks.vars(vars)
+
+	c.deadLetterMutex.Lock()
+	c.deadLetters = nil
+	c.deadLetterMutex.Unlock()
 	// Note: We don't reset c.runVars in defer because parallel goroutines might still be
 	// reading it when Run() returns (especially on error). It will be overwritten on next Run().
 
 	// Emit ROOT_START event
 	rootID := c.profiler.EmitRootStart(c.Config, c.ContextMap)
 
+	contexts := newContextChain(c.ContextMap)
 	for i, step := range c.Config.Steps {
 		stepPath := fmt.Sprintf("steps[%d]", i)
-		exec := c.newStepExecution(step, stepPath, currentContext, c.ContextMap, rootID)
+		exec := c.newStepExecution(step, stepPath, currentContext, contexts, rootID)
 		if err := c.ExecuteStep(ctx, exec); err != nil {
 			return err
 		}
 	}
 
+	// The crawl completed: advance the watermarks
+	if !c.Config.Stream {
+		if err := c.watermarks.observe(rootCtx.Data); err != nil {
+			return err
+		}
+	}
+	if err := c.watermarks.commit(); err != nil {
+		return err
+	}
+
+	// Nothing left to resume
+	if err := c.checkpoint.clear(); err != nil {
+		return fmt.Errorf("error clearing checkpoint: %w", err)
+	}
+
 	// Emit final result if not streaming
 	if !c.Config.Stream {
 		c.profiler.EmitFinalResult(rootID, rootCtx.Data)
@@ -263,16 +412,65 @@ func (c *ApiCrawler) Run(ctx context.Context, vars map[string]any) error {
 }
 
 func (c *ApiCrawler) ExecuteStep(ctx context.Context, exec *stepExecution) error {
+	// Restore the progress saved by an interrupted run, if any
+	exec.resumed = c.checkpoint.resume(exec)
+	if err := c.replayMerges(exec); err != nil {
+		return err
+	}
+	if exec.resumed != nil && exec.resumed.done {
+		c.logger.Info("[Checkpoint] Skipping %s: completed in a previous run", exec.step.Name)
+		return nil
+	}
+
+	// Evaluate the step condition first, if any. A condition is only ever
+	// evaluated from the compiled step, so a missing one must not run the step.
+	if exec.step.When != "" && (exec.compiledStep == nil || exec.compiledStep.When == nil) {
+		return fmt.Errorf("step %s has a when condition but was not compiled", exec.stepPath)
+	}
+	if exec.compiledStep != nil && exec.compiledStep.When != nil {
+		templateCtx := c.contextMapToTemplate(exec.contexts, c.runVars, &exec.compiledStep.WhenScope)
+		c.mergeMutex.Lock()
+		run, err := exec.compiledStep.ExecuteWhen(exec.currentContext.Data, templateCtx)
+		c.mergeMutex.Unlock()
+		if err != nil {
+			c.profiler.EmitError("Condition Error", exec.parentID, err.Error())
+			return fmt.Errorf("error evaluating condition of step %s: %w", exec.stepPath, err)
+		}
+		c.profiler.EmitStepCondition(exec.parentID, exec.step, exec.step.When, run)
+		if !run {
+			c.logger.Info("[When] Skipping %s: condition '%s' is false", exec.step.Name, exec.step.When)
+			return nil
+		}
+	}
+
+	var err error
 	switch exec.step.Type {
 	case "request":
-		return c.handleRequest(ctx, exec)
+		err = c.handleRequest(ctx, exec)
 	case "forEach":
-		return c.handleForEach(ctx, exec)
+		err = c.handleForEach(ctx, exec)
 	case "forValues":
-		return c.handleForValues(ctx, exec)
+		err = c.handleForValues(ctx, exec)
+	case "emit":
+		err = c.emit(ctx, exec, exec.currentContext, exec.parentID)
 	default:
 		return fmt.Errorf("unknown step type: %s", exec.step.Type)
 	}
+	if err != nil {
+		return err
+	}
+
+	return c.checkpoint.stepDone(exec)
+}
+
+// requestRun holds the state shared by the pages of a request step execution
+type requestRun struct {
+	exec          *stepExecution
+	stepID        string
+	templateCtx   map[string]any
+	authenticator Authenticator
+	retry         *retryPolicy
+	deadline      time.Time // no new page after this time (zero = no maxDuration)
 }
 
 func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) error {
@@ -282,12 +480,12 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 	stepStartTime := time.Now()
 	stepID := c.profiler.EmitRequestStepStart(exec.step, exec.parentID)
 
-	templateCtx := c.contextMapToTemplate(exec.contextMap, c.runVars)
+	templateCtx := c.contextMapToTemplate(exec.contexts, c.runVars, c.stepTemplateScope(exec))
 
 	// Determine authenticator (request-specific overrides global)
 	authenticator := c.globalAuthenticator
 	if exec.step.Request.Authentication != nil {
-		authenticator = NewAuthenticator(*exec.step.Request.Authentication, c.httpClient)
+		authenticator = NewAuthenticator(*exec.step.Request.Authentication, c.client)
 	}
 
 	// Set profiler on authenticator
@@ -296,14 +494,51 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 	}
 
 	// Initialize paginator
-	paginator, err := NewPaginator(ConfigP{exec.step.Request.Pagination})
+	var compiledPagination *CompiledPagination
+	if exec.compiledStep != nil {
+		compiledPagination = exec.compiledStep.Pagination
+	}
+	paginator, err := newCompiledPaginator(ConfigP{Pagination: exec.step.Request.Pagination, NumberMode: c.Config.NumberMode}, compiledPagination)
 	if err != nil {
 		c.profiler.EmitError("Paginator Error", stepID, err.Error())
 		return fmt.Errorf("error creating request paginator: %w", err)
 	}
 
+	// Resolve retry policy (request-specific overrides global)
+	retry, err := resolveRetryPolicy(exec.step.Request.Retry, c.Config.Retry)
+	if err != nil {
+		c.profiler.EmitError("Retry Config Error", stepID, err.Error())
+		return err
+	}
+
+	run := &requestRun{
+		exec:          exec,
+		stepID:        stepID,
+		templateCtx:   templateCtx,
+		authenticator: authenticator,
+		retry:         retry,
+	}
+	if maxDuration := exec.step.Request.Pagination.MaxDuration; maxDuration != "" {
+		d, err := time.ParseDuration(maxDuration)
+		if err != nil {
+			c.profiler.EmitError("Paginator Error", stepID, err.Error())
+			return fmt.Errorf("invalid pagination maxDuration: %w", err)
+		}
+		run.deadline = stepStartTime.Add(d)
+	}
+
+	// Continue after the last page completed by an interrupted run
+	if exec.resumed != nil && exec.resumed.paginator != nil {
+		paginator.Restore(*exec.resumed.paginator)
+		c.logger.Info("[Checkpoint] Resuming %s at page %d", exec.step.Name, paginator.PageNum())
+	}
+
 	stop := false
-	next := paginator.NextFromCtx()
+	next, err := paginator.RequestParts()
+	if err != nil {
+		c.profiler.EmitError("Pagination Error", stepID, err.Error())
+		return fmt.Errorf("error building pagination params: %w", err)
+	}
 
 	// Track previous response for PAGINATION_EVAL event
 	var previousResponseBody interface{}
@@ -324,106 +559,30 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 		pageStartTime := time.Now()
 		pageID := c.profiler.EmitRequestPageStart(stepID, exec.step, pageNum)
 
-		// Prepare HTTP request
-		reqCtx := httpRequestContext{
-			requestID:      pageID,
-			urlTemplate:    exec.step.Request.URL,
-			method:         exec.step.Request.Method,
-			headers:        exec.step.Request.Headers,
-			configuredBody: exec.step.Request.Body,
-			bodyParams:     next.BodyParams,
-			contentType:    getContentType(exec.step.Request.Headers),
-			queryParams:    next.QueryParams,
-			nextPageURL:    next.NextPageUrl,
-			authenticator:  authenticator,
-			compiledStep:   exec.compiledStep,
-		}
-
-		req, urlObj, mergedBody, err := c.prepareHTTPRequest(reqCtx, templateCtx)
+		resp, urlObj, durationMs, err := c.sendPage(ctx, run, pageID, pageNum, next)
 		if err != nil {
-			c.profiler.EmitError("Prepare Request Error", pageID, err.Error())
 			return err
 		}
-
-		// Emit URL_COMPOSITION event (only compute data if profiler enabled)
-		if c.profiler.Enabled() {
-			resultHeaders := make(map[string]string)
-			for k, v := range req.Header {
-				if len(v) > 0 {
-					resultHeaders[k] = v[0]
-				}
-			}
-
-			var resultBody interface{}
-			if req.Body != nil {
-				resultBody = mergedBody
-			}
-
-			c.profiler.EmitURLComposition(pageID, exec.step, URLCompositionData{
-				URLTemplate:     exec.step.Request.URL,
-				PageNumber:      pageNum,
-				QueryParams:     next.QueryParams,
-				BodyParams:      mergedBody,
-				NextPageURL:     next.NextPageUrl,
-				TemplateContext: templateCtx,
-				ResultURL:       urlObj.String(),
-				ResultHeaders:   resultHeaders,
-				ResultBody:      resultBody,
-			})
-		}
-
-		c.logger.Info("[Request] %s", urlObj.String())
-
-		// Emit REQUEST_DETAILS event (only compute data if profiler enabled)
-		if c.profiler.Enabled() {
-			// Build curl command
-			curlCmd := fmt.Sprintf("curl -X %s '%s'", req.Method, urlObj.String())
-			for k, v := range req.Header {
-				if len(v) > 0 {
-					curlCmd += fmt.Sprintf(" -H '%s: %s'", k, v[0])
-				}
-			}
-			if req.Body != nil && len(mergedBody) > 0 {
-				bodyJSON, _ := json.Marshal(mergedBody)
-				curlCmd += fmt.Sprintf(" -d '%s'", string(bodyJSON))
-			}
-
-			// Build headers map
-			headers := make(map[string]string)
-			for k, v := range req.Header {
-				if len(v) > 0 {
-					headers[k] = v[0]
-				}
-			}
-
-			c.profiler.EmitRequestDetails(pageID, exec.step, RequestDetailsData{
-				CurlCommand: curlCmd,
-				Method:      req.Method,
-				URL:         urlObj.String(),
-				Headers:     headers,
-				Body:        mergedBody,
-			})
-		}
-
-		c.logger.Debug("[Request] Got response: status pending")
-
-		// Execute HTTP request with timing
-		requestStartTime := time.Now()
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			c.profiler.EmitError("Request Error", pageID, err.Error())
-			return fmt.Errorf("error performing HTTP request: %w", err)
-		}
 		defer resp.Body.Close()
-		durationMs := time.Since(requestStartTime).Milliseconds()
 
-		// Compute response size
-		responseSize := int(resp.ContentLength)
-		if responseSize < 0 {
-			responseSize = 0
+		// Check the response status before pagination and decoding look at the body
+		emptyBody := false
+		switch resolveStatusAction(exec.step.Request, resp.StatusCode) {
+		case StatusActionFail:
+			statusErr := newHTTPStatusError(exec.stepPath, urlObj.String(), resp)
+			c.profiler.EmitError("HTTP Status Error", pageID, statusErr.Error())
+			return statusErr
+		case StatusActionSkip:
+			c.logger.Info("[Request] %s returned status %d, skipping", urlObj.String(), resp.StatusCode)
+			c.profiler.EmitRequestPageEnd(pageID, stepID, exec.step, pageNum, pageStartTime)
+			stop = true
+			continue
+		case StatusActionEmpty:
+			c.logger.Info("[Request] %s returned status %d, treating body as empty", urlObj.String(), resp.StatusCode)
+			emptyBody = true
 		}
 
-		// Capture pagination state BEFORE calling paginator.Next() (only if profiling)
+		// Capture pagination state BEFORE updating the paginator (only if profiling)
 		var previousPageState map[string]any
 		if c.profiler.Enabled() {
 			previousPageState = map[string]any{
@@ -436,18 +595,51 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 			}
 		}
 
-		// Update pagination state (reads and restores response body)
-		next, stop, err = paginator.Next(resp)
+		raw, responseSize, err := c.readPage(run, pageID, resp, emptyBody)
 		if err != nil {
-			c.profiler.EmitError("Paginator Error", pageID, err.Error())
-			return fmt.Errorf("paginator update error: %w", err)
+			return err
 		}
 
-		// Decode JSON response
-		var raw interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-			c.profiler.EmitError("Response Decode Error", pageID, err.Error())
-			return fmt.Errorf("error decoding response JSON: %w", err)
+		// The number of remaining pages is known once the first page arrives
+		totalPages := -1
+		if emptyBody {
+			// An empty body carries no pagination information: this is the last page
+			stop = true
+		} else {
+			if exec.step.Request.Pagination.Total != nil {
+				if totalPages, err = paginator.TotalPages(raw); err != nil {
+					c.profiler.EmitError("Paginator Error", pageID, err.Error())
+					return fmt.Errorf("paginator total error: %w", err)
+				}
+			}
+
+			// Update pagination state from the decoded body
+			next, stop, err = paginator.NextWithBody(resp, raw)
+			var loopErr *PaginationLoopError
+			if errors.As(err, &loopErr) {
+				loopErr.StepPath = exec.stepPath
+				if exec.step.Request.Pagination.OnLoop == OnLoopStop {
+					c.logger.Warning("[Request] %v, stopping pagination", loopErr)
+					c.profiler.EmitError("Pagination Loop", pageID, loopErr.Error())
+					stop, err = true, nil
+				}
+			}
+			if err != nil {
+				c.profiler.EmitError("Paginator Error", pageID, err.Error())
+				return fmt.Errorf("paginator update error: %w", err)
+			}
+			if totalPages >= 0 && paginator.PageNum() >= totalPages {
+				stop = true
+			}
+		}
+
+		// Stop at the safety limits, even if the API has more pages
+		if !stop {
+			if reason := run.paginationLimit(paginator.PageNum()); reason != "" {
+				c.logger.Warning("[Request] %s: %s, stopping pagination after page %d", exec.stepPath, reason, pageNum)
+				c.profiler.EmitError("Pagination Limit", pageID, reason)
+				stop = true
+			}
 		}
 
 		// Emit PAGINATION_EVAL event (if pagination is configured and this is not the first page)
@@ -471,23 +663,7 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 			})
 		}
 
-		// Emit REQUEST_RESPONSE event (only compute data if profiler enabled)
-		if c.profiler.Enabled() {
-			responseHeaders := make(map[string]string)
-			for k, v := range resp.Header {
-				if len(v) > 0 {
-					responseHeaders[k] = v[0]
-				}
-			}
-
-			c.profiler.EmitRequestResponse(pageID, exec.step, ResponseData{
-				StatusCode:   resp.StatusCode,
-				Headers:      responseHeaders,
-				Body:         raw,
-				ResponseSize: responseSize,
-				DurationMs:   durationMs,
-			})
-		}
+		c.emitPageResponse(run, pageID, resp, raw, responseSize, durationMs)
 
 		// Store response for next PAGINATION_EVAL event (only if profiling)
 		if c.profiler.Enabled() {
@@ -500,59 +676,46 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 			}
 		}
 
-		// Transform response
-		transformed, err := exec.compiledStep.ExecuteResultTransformer(raw, templateCtx)
+		transformed, err := c.processPage(ctx, run, pageID, pageNum, raw)
 		if err != nil {
-			c.profiler.EmitError("Response Transform Error", pageID, err.Error())
 			return err
 		}
 
-		// Emit RESPONSE_TRANSFORM event
-		c.profiler.EmitResponseTransform(pageID, exec.step, exec.step.ResultTransformer, raw, transformed)
-
-		// Execute nested steps on transformed result
-		// Note: request steps create a working context for the response data.
-		// If the current context is canonical (like "root"), the working context
-		// uses a unique key to avoid shadowing the original.
-		cloneResult := childMapWithClonedContext(exec.contextMap, exec.currentContext, transformed, c.ContextMap)
-		childContextMap := cloneResult.contextMap
-		workingContextKey := cloneResult.workingKey
-
-		// Emit CONTEXT_SELECTION event (context created for nested steps)
-		if len(exec.step.Steps) > 0 {
-			c.profiler.EmitContextSelection(pageID, exec.step, workingContextKey, childContextMap)
-		}
-
-		for i, step := range exec.step.Steps {
-			nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, i)
-			newExec := c.newStepExecution(step, nestedPath, workingContextKey, childContextMap, pageID)
-			if err := c.ExecuteStep(ctx, newExec); err != nil {
-				return err
-			}
+		if err := c.emit(ctx, exec, &Context{Data: transformed}, pageID); err != nil {
+			return err
 		}
 
-		// Get final result after nested steps from the working context
-		transformed = childContextMap[workingContextKey].Data
-
 		// Apply merge strategy (profiling is handled internally)
-		if err := c.performMerge(exec, transformed, templateCtx, pageID); err != nil {
+		if err := c.performMerge(exec, transformed, templateCtx, pageID, pageNum); err != nil {
 			c.profiler.EmitError("Merge Error", pageID, err.Error())
 			return err
 		}
 
 		// Handle streaming at root level
-		if exec.currentContext.depth == 0 && c.Config.Stream {
-			if arrayData, ok := exec.currentContext.Data.([]interface{}); ok {
-				for i, d := range arrayData {
-					c.DataStream <- d
-					c.profiler.EmitStreamResult(pageID, exec.step, d, i)
-				}
-				exec.currentContext.Data = []interface{}{}
+		if exec.currentContext.depth == 0 && c.streamsContexts() {
+			if err := c.streamData(ctx, exec, pageID, pageNum); err != nil {
+				return err
+			}
+		}
+
+		// Record the completed page so an interrupted run continues with the next one
+		if !stop {
+			if err := c.checkpoint.pageDone(exec, pageNum, paginator.State()); err != nil {
+				c.profiler.EmitError("Checkpoint Error", pageID, err.Error())
+				return err
 			}
 		}
 
 		// Emit REQUEST_PAGE_END event
 		c.profiler.EmitRequestPageEnd(pageID, stepID, exec.step, pageNum, pageStartTime)
+
+		// With a known total, the remaining pages do not depend on each other
+		if totalPages >= 0 && !stop {
+			if err := c.fetchRemainingPages(ctx, run, paginator, next, totalPages); err != nil {
+				return err
+			}
+			stop = true
+		}
 	}
 
 	// Emit REQUEST_STEP_END event
@@ -561,6 +724,402 @@ func (c *ApiCrawler) handleRequest(ctx context.Context, exec *stepExecution) err
 	return nil
 }
 
+// sendPage builds and sends the HTTP request of a page, including retries.
+// The caller must close the response body.
+func (c *ApiCrawler) sendPage(ctx context.Context, run *requestRun, pageID string, pageNum int, next *RequestParts) (*http.Response, *url.URL, int64, error) {
+	exec := run.exec
+
+	// Prepare HTTP request
+	reqCtx := httpRequestContext{
+		requestID:      pageID,
+		urlTemplate:    exec.step.Request.URL,
+		method:         exec.step.Request.Method,
+		headers:        exec.step.Request.Headers,
+		configuredBody: exec.step.Request.Body,
+		bodyParams:     next.BodyParams,
+		contentType:    getContentType(exec.step.Request.Headers),
+		queryParams:    next.QueryParams,
+		headerParams:   next.Headers,
+		nextPageURL:    next.NextPageUrl,
+		authenticator:  run.authenticator,
+		compiledStep:   exec.compiledStep,
+	}
+
+	req, urlObj, mergedBody, err := c.prepareHTTPRequest(reqCtx, run.templateCtx)
+	if err != nil {
+		c.profiler.EmitError("Prepare Request Error", pageID, err.Error())
+		return nil, nil, 0, err
+	}
+	// Abort the request when the crawl (or a failing parallel sibling) is cancelled
+	req = req.WithContext(ctx)
+
+	// Emit URL_COMPOSITION event (only compute data if profiler enabled)
+	if c.profiler.Enabled() {
+		resultHeaders := make(map[string]string)
+		for k, v := range req.Header {
+			if len(v) > 0 {
+				resultHeaders[k] = v[0]
+			}
+		}
+
+		var resultBody interface{}
+		if req.Body != nil {
+			resultBody = mergedBody
+		}
+
+		c.profiler.EmitURLComposition(pageID, exec.step, URLCompositionData{
+			URLTemplate:     exec.step.Request.URL,
+			PageNumber:      pageNum,
+			QueryParams:     next.QueryParams,
+			HeaderParams:    next.Headers,
+			BodyParams:      mergedBody,
+			NextPageURL:     next.NextPageUrl,
+			TemplateContext: run.templateCtx,
+			ResultURL:       urlObj.String(),
+			ResultHeaders:   resultHeaders,
+			ResultBody:      resultBody,
+		})
+	}
+
+	c.logger.Info("[Request] %s", urlObj.String())
+
+	// Emit REQUEST_DETAILS event (only compute data if profiler enabled)
+	if c.profiler.Enabled() {
+		// Build curl command
+		curlCmd := fmt.Sprintf("curl -X %s '%s'", req.Method, urlObj.String())
+		for k, v := range req.Header {
+			if len(v) > 0 {
+				curlCmd += fmt.Sprintf(" -H '%s: %s'", k, v[0])
+			}
+		}
+		if req.Body != nil && len(mergedBody) > 0 {
+			bodyJSON, _ := json.Marshal(mergedBody)
+			curlCmd += fmt.Sprintf(" -d '%s'", string(bodyJSON))
+		}
+
+		// Build headers map
+		headers := make(map[string]string)
+		for k, v := range req.Header {
+			if len(v) > 0 {
+				headers[k] = v[0]
+			}
+		}
+
+		c.profiler.EmitRequestDetails(pageID, exec.step, RequestDetailsData{
+			CurlCommand: curlCmd,
+			Method:      req.Method,
+			URL:         urlObj.String(),
+			Headers:     headers,
+			Body:        mergedBody,
+		})
+	}
+
+	c.logger.Debug("[Request] Got response: status pending")
+
+	// Execute HTTP request with timing (including retries)
+	requestStartTime := time.Now()
+	resp, err := c.doWithRetry(ctx, req, run.retry, pageID, exec.step)
+	if err != nil {
+		c.profiler.EmitError("Request Error", pageID, err.Error())
+		return nil, nil, 0, fmt.Errorf("error performing HTTP request: %w", err)
+	}
+	return resp, urlObj, time.Since(requestStartTime).Milliseconds(), nil
+}
+
+// readPage reads and decodes the response body of a page. An empty body
+// (see StatusActionEmpty) is not read and decodes to nil.
+func (c *ApiCrawler) readPage(run *requestRun, pageID string, resp *http.Response, emptyBody bool) (interface{}, int, error) {
+	// Compute response size
+	responseSize := int(resp.ContentLength)
+	if responseSize < 0 {
+		responseSize = 0
+	}
+	if emptyBody {
+		return nil, responseSize, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.profiler.EmitError("Request Error", pageID, err.Error())
+		return nil, 0, fmt.Errorf("error reading response body: %w", err)
+	}
+	if responseSize == 0 {
+		responseSize = len(body)
+	}
+
+	// Decode the response according to the configured (or detected) format
+	format := resolveResponseFormat(run.exec.step.Request.ResponseFormat, resp.Header.Get("Content-Type"))
+	raw, err := decodeBody(format, body, c.Config.NumberMode != NumberModeFloat)
+	if err != nil {
+		c.profiler.EmitError("Response Decode Error", pageID, err.Error())
+		return nil, 0, fmt.Errorf("error decoding response %s: %w", strings.ToUpper(format), err)
+	}
+	return raw, responseSize, nil
+}
+
+// emitPageResponse emits the REQUEST_RESPONSE event of a page (only computes data if profiler enabled)
+func (c *ApiCrawler) emitPageResponse(run *requestRun, pageID string, resp *http.Response, raw interface{}, responseSize int, durationMs int64) {
+	if !c.profiler.Enabled() {
+		return
+	}
+	responseHeaders := make(map[string]string)
+	for k, v := range resp.Header {
+		if len(v) > 0 {
+			responseHeaders[k] = v[0]
+		}
+	}
+
+	c.profiler.EmitRequestResponse(pageID, run.exec.step, ResponseData{
+		StatusCode:   resp.StatusCode,
+		Headers:      responseHeaders,
+		Body:         raw,
+		ResponseSize: responseSize,
+		DurationMs:   durationMs,
+	})
+}
+
+// processPage transforms the decoded response of a page and runs the nested
+// steps on it, returning the data to merge
+func (c *ApiCrawler) processPage(ctx context.Context, run *requestRun, pageID string, pageNum int, raw interface{}) (interface{}, error) {
+	exec := run.exec
+
+	// Transform response
+	transformed, err := exec.compiledStep.ExecuteResultTransformer(raw, run.templateCtx)
+	if err != nil {
+		c.profiler.EmitError("Response Transform Error", pageID, err.Error())
+		return nil, err
+	}
+
+	// Emit RESPONSE_TRANSFORM event
+	c.profiler.EmitResponseTransform(pageID, exec.step, exec.step.ResultTransformer, raw, transformed)
+
+	// Execute nested steps on transformed result
+	// Note: request steps create a working context for the response data.
+	// If the current context is canonical (like "root"), the working context
+	// uses a unique key to avoid shadowing the original.
+	childContexts, workingContext := withWorkingContext(exec.contexts, exec.currentContext, transformed, c.ContextMap)
+
+	// Emit CONTEXT_SELECTION event (context created for nested steps)
+	if len(exec.step.Steps) > 0 && c.profiler.Enabled() {
+		c.profiler.EmitContextSelection(pageID, exec.step, workingContext.key, childContexts.toMap())
+	}
+
+	for i, step := range exec.step.Steps {
+		nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, i)
+		newExec := c.newNestedExecution(exec, pageNum, step, nestedPath, workingContext.key, childContexts, pageID)
+		if err := c.ExecuteStep(ctx, newExec); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get final result after nested steps from the working context
+	return workingContext.Data, nil
+}
+
+// pageJob is a page requested once the total number of pages is known
+type pageJob struct {
+	pageNum int
+	next    *RequestParts
+	state   PaginatorState // paginator state before the page is requested
+}
+
+// pageResult is the outcome of a pageJob
+type pageResult struct {
+	pageID    string
+	startTime time.Time
+	data      interface{}
+	skipped   bool // the status code was configured to be skipped
+	limited   bool // not requested because maxDuration was reached
+}
+
+// fetchRemainingPages requests the pages up to totalPages, starting with next,
+// under the pagination parallelism. Results are merged in page order as soon as
+// all previous pages are merged.
+func (c *ApiCrawler) fetchRemainingPages(ctx context.Context, run *requestRun, paginator *Paginator, next *RequestParts, totalPages int) error {
+	exec := run.exec
+
+	var jobs []interface{}
+	for {
+		jobs = append(jobs, pageJob{pageNum: paginator.PageNum(), next: next, state: paginator.State()})
+		if paginator.PageNum()+1 >= totalPages {
+			break
+		}
+		if reason := run.paginationLimit(paginator.PageNum() + 1); reason != "" {
+			c.logger.Warning("[Request] %s: %s, stopping pagination after page %d", exec.stepPath, reason, paginator.PageNum())
+			c.profiler.EmitError("Pagination Limit", run.stepID, reason)
+			break
+		}
+		var err error
+		if next, err = paginator.Advance(); err != nil {
+			c.profiler.EmitError("Pagination Error", run.stepID, err.Error())
+			return fmt.Errorf("error building pagination params: %w", err)
+		}
+	}
+
+	parallelism := ParallelismConfig{MaxConcurrency: 1}
+	if exec.step.Request.Pagination.Parallelism != nil {
+		parallelism = *exec.step.Request.Pagination.Parallelism
+	}
+	maxConcurrency, rateLimiter := c.setupParallelism(exec, parallelism, run.stepID)
+
+	c.logger.Info("[Request] Fetching %d remaining pages of %s (max concurrency: %d)", len(jobs), exec.step.Name, maxConcurrency)
+
+	// jq normalizes its variables in place, so each worker gets its own template
+	// context while the merges use the shared one
+	workerRuns := make([]*requestRun, min(maxConcurrency, len(jobs)))
+	for i := range workerRuns {
+		workerRun := *run
+		workerRun.templateCtx = deepCopyAndNormalizeValue(run.templateCtx).(map[string]interface{})
+		workerRuns[i] = &workerRun
+	}
+
+	// Once maxDuration is reached no new page is requested, and the pages after
+	// the first one not requested are dropped so the merged data has no gaps
+	limitedPage := -1
+	_, err := c.executeParallel(ctx, jobs, parallelism, maxConcurrency, rateLimiter, run.stepID,
+		func(ctx context.Context, index int, item any, workerID int, workerPoolID string) iterationResult {
+			if reason := run.paginationLimit(0); reason != "" {
+				return iterationResult{index: index, result: pageResult{limited: true}}
+			}
+			page, err := c.fetchPage(ctx, workerRuns[workerID], item.(pageJob))
+			return iterationResult{index: index, result: page, err: err}
+		},
+		func(index int, result any) error {
+			job, page := jobs[index].(pageJob), result.(pageResult)
+			if page.limited && limitedPage < 0 {
+				limitedPage = job.pageNum
+				c.logger.Warning("[Request] %s: %s, stopping pagination after page %d", exec.stepPath, run.paginationLimit(0), job.pageNum-1)
+				c.profiler.EmitError("Pagination Limit", run.stepID, run.paginationLimit(0))
+			}
+			if limitedPage >= 0 {
+				if !page.limited {
+					c.profiler.EmitRequestPageEnd(page.pageID, run.stepID, exec.step, job.pageNum, page.startTime)
+				}
+				return nil
+			}
+			if !page.skipped {
+				if err := c.emit(ctx, exec, &Context{Data: page.data}, page.pageID); err != nil {
+					return err
+				}
+				if err := c.performMerge(exec, page.data, run.templateCtx, page.pageID, job.pageNum); err != nil {
+					c.profiler.EmitError("Merge Error", page.pageID, err.Error())
+					return err
+				}
+				if exec.currentContext.depth == 0 && c.streamsContexts() {
+					if err := c.streamData(ctx, exec, page.pageID, job.pageNum); err != nil {
+						return err
+					}
+				}
+			}
+			// Resume with the following page, unless this was the last one
+			if index+1 < len(jobs) {
+				if err := c.checkpoint.pageDone(exec, job.pageNum, jobs[index+1].(pageJob).state); err != nil {
+					c.profiler.EmitError("Checkpoint Error", page.pageID, err.Error())
+					return err
+				}
+			}
+			c.profiler.EmitRequestPageEnd(page.pageID, run.stepID, exec.step, job.pageNum, page.startTime)
+			return nil
+		})
+	return err
+}
+
+// fetchPage requests, decodes and processes a single page of a pageJob
+func (c *ApiCrawler) fetchPage(ctx context.Context, run *requestRun, job pageJob) (pageResult, error) {
+	exec := run.exec
+	page := pageResult{startTime: time.Now()}
+	page.pageID = c.profiler.EmitRequestPageStart(run.stepID, exec.step, job.pageNum)
+
+	resp, urlObj, durationMs, err := c.sendPage(ctx, run, page.pageID, job.pageNum, job.next)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	emptyBody := false
+	switch resolveStatusAction(exec.step.Request, resp.StatusCode) {
+	case StatusActionFail:
+		statusErr := newHTTPStatusError(exec.stepPath, urlObj.String(), resp)
+		c.profiler.EmitError("HTTP Status Error", page.pageID, statusErr.Error())
+		return page, statusErr
+	case StatusActionSkip:
+		c.logger.Info("[Request] %s returned status %d, skipping", urlObj.String(), resp.StatusCode)
+		page.skipped = true
+		return page, nil
+	case StatusActionEmpty:
+		c.logger.Info("[Request] %s returned status %d, treating body as empty", urlObj.String(), resp.StatusCode)
+		emptyBody = true
+	}
+
+	raw, responseSize, err := c.readPage(run, page.pageID, resp, emptyBody)
+	if err != nil {
+		return page, err
+	}
+	c.emitPageResponse(run, page.pageID, resp, raw, responseSize, durationMs)
+
+	page.data, err = c.processPage(ctx, run, page.pageID, job.pageNum, raw)
+	return page, err
+}
+
+// streamsContexts reports whether the data merged into the root context is
+// streamed. Configs that use emit only stream the values they emit.
+func (c *ApiCrawler) streamsContexts() bool {
+	return c.Config.Stream && (c.CompiledConfig == nil || !c.CompiledConfig.Emits)
+}
+
+// emit sends the values of the step's emit expression, evaluated against the data
+// of source, to the output of the step. The data is read under mergeMutex, as parallel
+// iterations may still merge into source. The values are copied, so later merges into
+// the contexts they were taken from cannot change them while the consumer reads them.
+func (c *ApiCrawler) emit(ctx context.Context, exec *stepExecution, source *Context, parentID string) error {
+	if exec.compiledStep == nil || exec.compiledStep.Emit == nil {
+		return nil
+	}
+
+	templateCtx := c.contextMapToTemplate(exec.contexts, c.runVars, &exec.compiledStep.EmitScope)
+	c.mergeMutex.Lock()
+	values, err := exec.compiledStep.ExecuteEmit(source.Data, templateCtx)
+	for i, v := range values {
+		values[i] = copyDataSafe(v)
+	}
+	c.mergeMutex.Unlock()
+	if err != nil {
+		c.profiler.EmitError("Emit Error", parentID, err.Error())
+		return fmt.Errorf("error evaluating emit of step %s: %w", exec.stepPath, err)
+	}
+
+	for i, v := range values {
+		if err := c.watermarks.observe(v); err != nil {
+			c.profiler.EmitError("Watermark Error", parentID, err.Error())
+			return err
+		}
+		if err := c.send(ctx, exec.step.Output, v); err != nil {
+			return err
+		}
+		c.profiler.EmitStreamResult(parentID, exec.step, v, i)
+	}
+	return nil
+}
+
+// streamData sends the data accumulated in the current (root) context to the output
+// of the step. page is the request page whose data is streamed (-1 if none).
+func (c *ApiCrawler) streamData(ctx context.Context, exec *stepExecution, parentID string, page int) error {
+	for i, d := range c.takeStreamData(exec, page) {
+		if err := c.watermarks.observe(d); err != nil {
+			c.profiler.EmitError("Watermark Error", parentID, err.Error())
+			return err
+		}
+		if err := c.send(ctx, exec.step.Output, d); err != nil {
+			return err
+		}
+		c.profiler.EmitStreamResult(parentID, exec.step, d, i)
+	}
+	return nil
+}
+
+// iterationFunc executes a single iteration of a parallel step on a worker
+type iterationFunc func(ctx context.Context, index int, item any, workerID int, workerPoolID string) iterationResult
+
 // executeForEachIteration executes a single forEach iteration
 func (c *ApiCrawler) executeForEachIteration(
 	ctx context.Context,
@@ -570,91 +1129,206 @@ func (c *ApiCrawler) executeForEachIteration(
 	stepID string,
 	workerID int,
 	workerPoolID string,
-) forEachResult {
-	result := forEachResult{
+) iterationResult {
+	result := iterationResult{
 		index:          index,
 		profilerEvents: make([]StepProfilerData, 0),
 	}
 
 	c.logger.Info("[ForEach] Iteration %d as '%s'", index, exec.step.As, "item", item)
 
-	childContextMap := childMapWith(exec.contextMap, exec.currentContext, exec.step.As, item)
+	childContexts, itemContext := withChildContext(exec.contexts, exec.currentContext, exec.step.As, item)
 
 	// Emit CONTEXT_SELECTION event with worker tracking (context created for iteration)
-	c.profiler.EmitContextSelectionWithWorker(stepID, exec.step, exec.step.As, childContextMap, workerID, workerPoolID)
+	if c.profiler.Enabled() {
+		c.profiler.EmitContextSelectionWithWorker(stepID, exec.step, exec.step.As, childContexts.toMap(), workerID, workerPoolID)
+	}
 
 	// Emit ITEM_SELECTION event with worker tracking
-	itemID := c.profiler.EmitItemSelectionWithWorker(stepID, exec.step, index, item, exec.step.As, childContextMap[exec.step.As].Data, workerID, workerPoolID)
+	itemID := c.profiler.EmitItemSelectionWithWorker(stepID, exec.step, index, item, exec.step.As, itemContext.Data, workerID, workerPoolID)
 
 	// Execute nested steps
 	for i, nested := range exec.step.Steps {
 		nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, i)
-		newExec := c.newStepExecution(nested, nestedPath, exec.step.As, childContextMap, itemID)
+		newExec := c.newNestedExecution(exec, index, nested, nestedPath, exec.step.As, childContexts, itemID)
 		if err := c.ExecuteStep(ctx, newExec); err != nil {
 			result.err = err
 			return result
 		}
 	}
 
-	result.result = childContextMap[exec.step.As].Data
+	if err := c.emit(ctx, exec, itemContext, itemID); err != nil {
+		result.err = err
+		return result
+	}
+
+	result.result = itemContext.Data
 
 	return result
 }
 
-// executeForEachParallel executes forEach iterations in parallel
-func (c *ApiCrawler) executeForEachParallel(
+// executeForValuesIteration executes a single forValues iteration.
+// The iteration result is always nil: nested steps merge into the parent context themselves.
+func (c *ApiCrawler) executeForValuesIteration(
 	ctx context.Context,
+	index int,
+	value any,
 	exec *stepExecution,
+	stepID string,
+	workerID int,
+	workerPoolID string,
+) iterationResult {
+	result := iterationResult{
+		index:          index,
+		profilerEvents: make([]StepProfilerData, 0),
+	}
+
+	c.logger.Info("[ForValues] Iteration %d as '%s' = %v", index, exec.step.As, value)
+
+	// Create overlay context map - value is assigned directly (no .value wrapper)
+	// This preserves parent context while adding the new value
+	childContexts := withOverlayContext(exec.contexts, exec.currentContext, exec.step.As, value)
+
+	// Emit CONTEXT_SELECTION event with worker tracking
+	if c.profiler.Enabled() {
+		c.profiler.EmitContextSelectionWithWorker(stepID, exec.step, exec.step.As, childContexts.toMap(), workerID, workerPoolID)
+	}
+
+	// Emit ITEM_SELECTION event (for forValues, use value selection)
+	itemID := c.profiler.EmitValueSelectionWithWorker(stepID, exec.step, index, value, exec.step.As, workerID, workerPoolID)
+
+	// Execute nested steps with overlay context
+	// Nested steps operate in parent context but have access to the value via 'as' key
+	for i, nested := range exec.step.Steps {
+		nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, i)
+		newExec := c.newNestedExecution(exec, index, nested, nestedPath, exec.currentContextKey, childContexts, itemID)
+		if err := c.ExecuteStep(ctx, newExec); err != nil {
+			result.err = err
+			return result
+		}
+	}
+
+	result.err = c.emit(ctx, exec, exec.currentContext, itemID)
+	return result
+}
+
+// setupParallelism resolves the concurrency limit and rate limiter of a parallel
+// step and emits the PARALLELISM_SETUP event.
+func (c *ApiCrawler) setupParallelism(exec *stepExecution, parallelism ParallelismConfig, stepID string) (int, *rate.Limiter) {
+	// Determine max concurrency (step setting or default)
+	maxConcurrency := parallelism.MaxConcurrency
+	if maxConcurrency == 0 {
+		maxConcurrency = 10 // Default concurrency
+	}
+
+	// Create rate limiter if configured
+	var rateLimiter *rate.Limiter
+	if parallelism.RequestsPerSecond > 0 {
+		burst := parallelism.Burst
+		if burst == 0 {
+			burst = 1 // Default burst
+		}
+		rateLimiter = rate.NewLimiter(rate.Limit(parallelism.RequestsPerSecond), burst)
+	}
+
+	// Emit PARALLELISM_SETUP event
+	if c.profiler.Enabled() {
+		workerPoolID := stepID + "-pool"
+		workerIDs := make([]int, maxConcurrency)
+		for i := 0; i < maxConcurrency; i++ {
+			workerIDs[i] = i
+		}
+
+		var rateLimit float64
+		var burst int
+		if rateLimiter != nil {
+			rateLimit = parallelism.RequestsPerSecond
+			burst = parallelism.Burst
+		}
+
+		c.profiler.EmitParallelismSetup(stepID, exec.step, ParallelismSetupData{
+			MaxConcurrency: maxConcurrency,
+			WorkerPoolID:   workerPoolID,
+			WorkerIDs:      workerIDs,
+			RateLimit:      rateLimit,
+			Burst:          burst,
+		})
+	}
+
+	return maxConcurrency, rateLimiter
+}
+
+// parallelWindowFactor bounds how far workers may run ahead of the oldest
+// unfinished item: at most maxConcurrency*parallelWindowFactor iterations are
+// in flight or waiting to be emitted in order.
+const parallelWindowFactor = 2
+
+// executeParallel runs one iteration per item on a fixed pool of maxConcurrency
+// workers, bounded by the optional rate limiter. Items are handed out lazily and
+// results are emitted in item order, so goroutines and buffered results are
+// bounded by the concurrency rather than by the number of items.
+//
+// Iterations run with a context derived from ctx. In failFast mode the first
+// error cancels it, so running iterations abort and no new ones start; in
+// collectErrors mode every iteration runs and the errors are joined in item
+// order. Either way all workers have stopped when executeParallel returns.
+//
+// If emit is set, each successful result is handed to it in item order instead
+// of being returned; an emit error stops the execution like a failFast error.
+func (c *ApiCrawler) executeParallel(
+	ctx context.Context,
 	items []interface{},
+	parallelism ParallelismConfig,
 	maxConcurrency int,
 	rateLimiter *rate.Limiter,
 	stepID string,
+	iterate iterationFunc,
+	emit func(index int, result any) error,
 ) ([]interface{}, error) {
-	profilerEnabled := c.profiler.Enabled()
 	numItems := len(items)
+	executionResults := make([]interface{}, numItems)
+	if numItems == 0 {
+		return executionResults, nil
+	}
 
-	// Results channel sized to hold all results
-	resultsChan := make(chan forEachResult, numItems)
-
-	// Error group for managing goroutines
-	var wg sync.WaitGroup
-
-	// Semaphore for concurrency control
-	semaphore := make(chan struct{}, maxConcurrency)
+	collectErrors := parallelism.ErrorMode == ParallelErrorModeCollectErrors
+	numWorkers := min(maxConcurrency, numItems)
+	workerPoolID := stepID + "-pool"
 
-	// Launch workers for each item
-	for i, item := range items {
-		wg.Add(1)
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		go func(index int, item any, threadID int) {
-			defer wg.Done()
+	// window holds one slot per item handed out but not yet emitted in order
+	window := make(chan struct{}, numWorkers*parallelWindowFactor)
 
-			// Acquire semaphore slot
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Check context cancellation
+	// Feed item indices to the workers until done or cancelled
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range items {
 			select {
-			case <-ctx.Done():
-				resultsChan <- forEachResult{index: index, err: ctx.Err()}
+			case window <- struct{}{}:
+			case <-workerCtx.Done():
 				return
-			default:
 			}
-
-			// Apply rate limiting if configured
-			if rateLimiter != nil {
-				if err := rateLimiter.Wait(ctx); err != nil {
-					resultsChan <- forEachResult{index: index, err: err}
-					return
-				}
+			select {
+			case jobs <- i:
+			case <-workerCtx.Done():
+				return
 			}
+		}
+	}()
 
-			// Execute iteration
-			workerPoolID := stepID + "-pool"
-			result := c.executeForEachIteration(ctx, index, item, exec, stepID, threadID, workerPoolID)
-			result.threadID = threadID
-			resultsChan <- result
-		}(i, item, i%maxConcurrency)
+	resultsChan := make(chan iterationResult, numWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for index := range jobs {
+				resultsChan <- c.runParallelIteration(workerCtx, index, items[index], workerID, workerPoolID, rateLimiter, iterate)
+			}
+		}(w)
 	}
 
 	// Close results channel when all workers complete
@@ -663,33 +1337,92 @@ func (c *ApiCrawler) executeForEachParallel(
 		close(resultsChan)
 	}()
 
-	// Collect results and maintain order
-	results := make([]forEachResult, numItems)
+	// Collect results, emitting them in item order as soon as the oldest pending one completes
+	pending := make(map[int]iterationResult, cap(window))
+	next := 0
+	var firstErr error
+	var errs []error
 	for result := range resultsChan {
-		if result.err != nil {
-			return nil, result.err
+		if firstErr != nil {
+			continue // drain the iterations still shutting down
 		}
-		results[result.index] = result
-	}
-
-	// Emit profiler events in order if profiling is enabled
-	if profilerEnabled {
-		for _, result := range results {
-			for _, event := range result.profilerEvents {
+		// A cancelled crawl always stops; otherwise only failFast stops on errors
+		if result.err != nil && (!collectErrors || ctx.Err() != nil) {
+			firstErr = result.err
+			if ctx.Err() != nil {
+				firstErr = ctx.Err()
+			}
+			cancel()
+			continue
+		}
+		pending[result.index] = result
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			for _, event := range ready.profilerEvents {
 				c.profiler.emit(event)
 			}
+			switch {
+			case ready.err != nil:
+				errs = append(errs, fmt.Errorf("iteration %d: %w", next, ready.err))
+			case emit != nil:
+				if err := emit(next, ready.result); err != nil {
+					firstErr = err
+					cancel()
+				}
+			default:
+				executionResults[next] = ready.result
+			}
+			next++
+			<-window
+			if firstErr != nil {
+				break
+			}
 		}
 	}
-
-	// Extract execution results in order
-	executionResults := make([]interface{}, numItems)
-	for i, result := range results {
-		executionResults[i] = result.result
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
 	return executionResults, nil
 }
 
+// runParallelIteration runs a single iteration on a pool worker, honoring
+// cancellation and the rate limiter
+func (c *ApiCrawler) runParallelIteration(
+	ctx context.Context,
+	index int,
+	item any,
+	workerID int,
+	workerPoolID string,
+	rateLimiter *rate.Limiter,
+	iterate iterationFunc,
+) iterationResult {
+	// Check context cancellation
+	select {
+	case <-ctx.Done():
+		return iterationResult{index: index, err: ctx.Err()}
+	default:
+	}
+
+	// Apply rate limiting if configured
+	if rateLimiter != nil {
+		if err := rateLimiter.Wait(ctx); err != nil {
+			return iterationResult{index: index, err: err}
+		}
+	}
+
+	result := iterate(ctx, index, item, workerID, workerPoolID)
+	result.threadID = workerID
+	return result
+}
+
 func (c *ApiCrawler) handleForEach(ctx context.Context, exec *stepExecution) error {
 	c.logger.Info("[Foreach] Preparing %s", exec.step.Name)
 
@@ -703,7 +1436,10 @@ func (c *ApiCrawler) handleForEach(ctx context.Context, exec *stepExecution) err
 	// Extract items to iterate over from path (forEach always uses path, validation ensures this)
 	c.logger.Debug("[Foreach] Extracting from parent context with rule: %s", exec.step.Path)
 
+	// The current context may be shared with parallel siblings (e.g. inside forValues)
+	c.mergeMutex.Lock()
 	results, err = exec.compiledStep.ExecutePathExtractor(exec.currentContext.Data)
+	c.mergeMutex.Unlock()
 	if err != nil {
 		c.profiler.EmitError("Path Extraction Error", stepID, err.Error())
 		return fmt.Errorf("path extraction failed: %w", err)
@@ -725,50 +1461,28 @@ func (c *ApiCrawler) handleForEach(ctx context.Context, exec *stepExecution) err
 	var executionResults []interface{}
 
 	if exec.step.Parallelism != nil {
-		// Determine max concurrency (step setting or default)
-		maxConcurrency := exec.step.Parallelism.MaxConcurrency
-		if maxConcurrency == 0 {
-			maxConcurrency = 10 // Default concurrency
-		}
-
-		// Create rate limiter if configured
-		var rateLimiter *rate.Limiter
-		if exec.step.Parallelism.RequestsPerSecond > 0 {
-			burst := exec.step.Parallelism.Burst
-			if burst == 0 {
-				burst = 1 // Default burst
-			}
-			rateLimiter = rate.NewLimiter(rate.Limit(exec.step.Parallelism.RequestsPerSecond), burst)
-		}
-
-		// Emit PARALLELISM_SETUP event
-		if c.profiler.Enabled() {
-			workerPoolID := stepID + "-pool"
-			workerIDs := make([]int, maxConcurrency)
-			for i := 0; i < maxConcurrency; i++ {
-				workerIDs[i] = i
-			}
-
-			var rateLimit float64
-			var burst int
-			if rateLimiter != nil {
-				rateLimit = exec.step.Parallelism.RequestsPerSecond
-				burst = exec.step.Parallelism.Burst
-			}
-
-			c.profiler.EmitParallelismSetup(stepID, exec.step, ParallelismSetupData{
-				MaxConcurrency: maxConcurrency,
-				WorkerPoolID:   workerPoolID,
-				WorkerIDs:      workerIDs,
-				RateLimit:      rateLimit,
-				Burst:          burst,
-			})
-		}
+		maxConcurrency, rateLimiter := c.setupParallelism(exec, *exec.step.Parallelism, stepID)
 
 		c.logger.Info("[ForEach] Executing %d iterations in parallel (max concurrency: %d)", len(results), maxConcurrency)
 
 		// Execute in parallel
-		executionResults, err = c.executeForEachParallel(ctx, exec, results, maxConcurrency, rateLimiter, stepID)
+		executionResults, err = c.executeParallel(ctx, results, *exec.step.Parallelism, maxConcurrency, rateLimiter, stepID,
+			func(ctx context.Context, index int, item any, workerID int, workerPoolID string) iterationResult {
+				// Reuse the result of iterations completed by an interrupted run
+				if exec.resumed.isCompleted(index) {
+					return iterationResult{index: index, result: exec.resumed.results[index]}
+				}
+				result := c.executeForEachIteration(ctx, index, item, exec, stepID, workerID, workerPoolID)
+				if result.err != nil {
+					// A skipped or collected item keeps its original data
+					if result.err = c.handleIterationError(ctx, exec, index, item, stepID, result.err); result.err == nil {
+						result.result = item
+					}
+					return result
+				}
+				result.err = c.checkpoint.iterationDone(exec, index, result.result)
+				return result
+			}, nil)
 		if err != nil {
 			return err
 		}
@@ -783,37 +1497,69 @@ func (c *ApiCrawler) handleForEach(ctx context.Context, exec *stepExecution) err
 			default:
 			}
 
+			// Reuse the result of iterations completed by an interrupted run
+			if exec.resumed.isCompleted(i) {
+				c.logger.Debug("[ForEach] Iteration %d completed in a previous run", i)
+				executionResults = append(executionResults, exec.resumed.results[i])
+				continue
+			}
+
 			c.logger.Info("[ForEach] Iteration %d as '%s'", i, exec.step.As, "item", item)
 
-			childContextMap := childMapWith(exec.contextMap, exec.currentContext, exec.step.As, item)
+			childContexts, itemContext := withChildContext(exec.contexts, exec.currentContext, exec.step.As, item)
 
 			// Emit CONTEXT_SELECTION event (context created for iteration)
-			c.profiler.EmitContextSelection(stepID, exec.step, exec.step.As, childContextMap)
+			if c.profiler.Enabled() {
+				c.profiler.EmitContextSelection(stepID, exec.step, exec.step.As, childContexts.toMap())
+			}
 
 			// Emit ITEM_SELECTION event
-			itemID := c.profiler.EmitItemSelection(stepID, exec.step, i, item, exec.step.As, childContextMap[exec.step.As].Data)
+			itemID := c.profiler.EmitItemSelection(stepID, exec.step, i, item, exec.step.As, itemContext.Data)
 
+			failed := false
 			for j, nested := range exec.step.Steps {
 				nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, j)
-				newExec := c.newStepExecution(nested, nestedPath, exec.step.As, childContextMap, itemID)
+				newExec := c.newNestedExecution(exec, i, nested, nestedPath, exec.step.As, childContexts, itemID)
 				if err := c.ExecuteStep(ctx, newExec); err != nil {
-					return err
+					if err := c.handleIterationError(ctx, exec, i, item, itemID, err); err != nil {
+						return err
+					}
+					failed = true
+					break
+				}
+			}
+			if !failed {
+				if err := c.emit(ctx, exec, itemContext, itemID); err != nil {
+					if err := c.handleIterationError(ctx, exec, i, item, itemID, err); err != nil {
+						return err
+					}
+					failed = true
 				}
 			}
+			if failed {
+				// A skipped or collected item keeps its original data
+				executionResults = append(executionResults, item)
+				continue
+			}
+
+			executionResults = append(executionResults, itemContext.Data)
 
-			executionResults = append(executionResults, childContextMap[exec.step.As].Data)
+			if err := c.checkpoint.iterationDone(exec, i, itemContext.Data); err != nil {
+				c.profiler.EmitError("Checkpoint Error", itemID, err.Error())
+				return err
+			}
 		}
 	}
 
 	// Determine merge strategy
-	templateCtx := c.contextMapToTemplate(exec.contextMap, c.runVars)
+	templateCtx := c.contextMapToTemplate(exec.contexts, c.runVars, c.stepTemplateScope(exec))
 
 	// Check if custom merge rules are specified (compiled merge exists)
 	hasCustomMerge := exec.compiledStep.Merge != nil || exec.step.NoopMerge
 
 	if hasCustomMerge {
 		// Use custom merge logic (same as request steps)
-		if err := c.performMerge(exec, executionResults, templateCtx, stepID); err != nil {
+		if err := c.performMerge(exec, executionResults, templateCtx, stepID, -1); err != nil {
 			c.profiler.EmitError("Merge Error", stepID, err.Error())
 			return err
 		}
@@ -824,23 +1570,23 @@ func (c *ApiCrawler) handleForEach(ctx context.Context, exec *stepExecution) err
 			return fmt.Errorf("synthetic merge not compiled for step")
 		}
 
+		c.mergeMutex.Lock()
 		mergedData, mergeErr := exec.compiledStep.ExecuteSyntheticMerge(exec.currentContext.Data, executionResults)
+		if mergeErr == nil {
+			exec.currentContext.Data = mergedData
+			c.checkpoint.merged(exec, exec.currentContext, -1, &CheckpointMerge{Result: executionResults, Patch: true})
+		}
+		c.mergeMutex.Unlock()
 		if mergeErr != nil {
 			c.profiler.EmitError("Merge Error", stepID, mergeErr.Error())
 			return fmt.Errorf("synthetic merge failed: %w", mergeErr)
 		}
-
-		exec.currentContext.Data = mergedData
 	}
 
 	// Handle streaming at root level
-	if exec.currentContext.depth <= 1 && c.Config.Stream {
-		if arrayData, ok := exec.currentContext.Data.([]interface{}); ok {
-			for i, d := range arrayData {
-				c.DataStream <- d
-				c.profiler.EmitStreamResult(stepID, exec.step, d, i)
-			}
-			exec.currentContext.Data = []interface{}{}
+	if exec.currentContext.depth <= 1 && c.streamsContexts() {
+		if err := c.streamData(ctx, exec, stepID, -1); err != nil {
+			return err
 		}
 	}
 
@@ -863,33 +1609,87 @@ func (c *ApiCrawler) handleForValues(ctx context.Context, exec *stepExecution) e
 	stepStartTime := time.Now()
 	stepID := c.profiler.EmitForValuesStepStart(exec.step, exec.parentID)
 
-	// Iterate over values
-	for i, value := range exec.step.Values {
-		// Check for context cancellation
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	if exec.step.Parallelism != nil {
+		maxConcurrency, rateLimiter := c.setupParallelism(exec, *exec.step.Parallelism, stepID)
+
+		c.logger.Info("[ForValues] Executing %d iterations in parallel (max concurrency: %d)", len(exec.step.Values), maxConcurrency)
+
+		// Nested steps merge into the shared parent context under mergeMutex
+		_, err := c.executeParallel(ctx, exec.step.Values, *exec.step.Parallelism, maxConcurrency, rateLimiter, stepID,
+			func(ctx context.Context, index int, value any, workerID int, workerPoolID string) iterationResult {
+				// Skip iterations completed by an interrupted run
+				if exec.resumed.isCompleted(index) {
+					return iterationResult{index: index}
+				}
+				result := c.executeForValuesIteration(ctx, index, value, exec, stepID, workerID, workerPoolID)
+				if result.err != nil {
+					result.err = c.handleIterationError(ctx, exec, index, value, stepID, result.err)
+					return result
+				}
+				result.err = c.checkpoint.iterationDone(exec, index, nil)
+				return result
+			}, nil)
+		if err != nil {
+			return err
 		}
+	} else {
+		// Iterate over values sequentially
+		for i, value := range exec.step.Values {
+			// Check for context cancellation
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
 
-		c.logger.Info("[ForValues] Iteration %d as '%s' = %v", i, exec.step.As, value)
+			// Skip iterations completed by an interrupted run
+			if exec.resumed.isCompleted(i) {
+				c.logger.Debug("[ForValues] Iteration %d completed in a previous run", i)
+				continue
+			}
+
+			c.logger.Info("[ForValues] Iteration %d as '%s' = %v", i, exec.step.As, value)
 
-		// Create overlay context map - value is assigned directly (no .value wrapper)
-		// This preserves parent context while adding the new value
-		childContextMap := childMapWithOverlay(exec.contextMap, exec.currentContext, exec.step.As, value)
+			// Create overlay context map - value is assigned directly (no .value wrapper)
+			// This preserves parent context while adding the new value
+			childContexts := withOverlayContext(exec.contexts, exec.currentContext, exec.step.As, value)
 
-		// Emit CONTEXT_SELECTION event
-		c.profiler.EmitContextSelection(stepID, exec.step, exec.step.As, childContextMap)
+			// Emit CONTEXT_SELECTION event
+			if c.profiler.Enabled() {
+				c.profiler.EmitContextSelection(stepID, exec.step, exec.step.As, childContexts.toMap())
+			}
 
-		// Emit ITEM_SELECTION event (for forValues, use value selection)
-		itemID := c.profiler.EmitValueSelection(stepID, exec.step, i, value, exec.step.As)
+			// Emit ITEM_SELECTION event (for forValues, use value selection)
+			itemID := c.profiler.EmitValueSelection(stepID, exec.step, i, value, exec.step.As)
 
-		// Execute nested steps with overlay context
-		// Nested steps operate in parent context but have access to the value via 'as' key
-		for j, nested := range exec.step.Steps {
-			nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, j)
-			newExec := c.newStepExecution(nested, nestedPath, exec.currentContextKey, childContextMap, itemID)
-			if err := c.ExecuteStep(ctx, newExec); err != nil {
+			// Execute nested steps with overlay context
+			// Nested steps operate in parent context but have access to the value via 'as' key
+			failed := false
+			for j, nested := range exec.step.Steps {
+				nestedPath := fmt.Sprintf("%s.steps[%d]", exec.stepPath, j)
+				newExec := c.newNestedExecution(exec, i, nested, nestedPath, exec.currentContextKey, childContexts, itemID)
+				if err := c.ExecuteStep(ctx, newExec); err != nil {
+					if err := c.handleIterationError(ctx, exec, i, value, itemID, err); err != nil {
+						return err
+					}
+					failed = true
+					break
+				}
+			}
+			if !failed {
+				if err := c.emit(ctx, exec, exec.currentContext, itemID); err != nil {
+					if err := c.handleIterationError(ctx, exec, i, value, itemID, err); err != nil {
+						return err
+					}
+					failed = true
+				}
+			}
+			if failed {
+				continue
+			}
+
+			if err := c.checkpoint.iterationDone(exec, i, nil); err != nil {
+				c.profiler.EmitError("Checkpoint Error", itemID, err.Error())
 				return err
 			}
 		}
@@ -904,7 +1704,8 @@ func (c *ApiCrawler) handleForValues(ctx context.Context, exec *stepExecution) e
 // performMerge applies the appropriate merge strategy based on step configuration.
 // Handles profiling internally (captures before/after state and emits events).
 // Thread-safe: Uses mutex to protect concurrent access to contexts.
-func (c *ApiCrawler) performMerge(exec *stepExecution, result any, templateCtx map[string]any, pageID string) error {
+// page is the request page the result comes from (-1 for forEach results).
+func (c *ApiCrawler) performMerge(exec *stepExecution, result any, templateCtx map[string]any, pageID string, page int) error {
 	// Check for noop merge (skip merging entirely)
 	if exec.step.NoopMerge {
 		c.logger.Debug("[Merge] noop merge - skipping")
@@ -936,12 +1737,12 @@ func (c *ApiCrawler) performMerge(exec *stepExecution, result any, templateCtx m
 			targetContextKey = exec.currentContext.key
 			c.logger.Debug("[Merge] merging to current context with expression: %s", merge.SourceRule)
 		case MergeTargetParent:
-			targetCtx = exec.contextMap[exec.currentContext.ParentContext]
+			targetCtx, _ = exec.contexts.get(exec.currentContext.ParentContext)
 			targetContextKey = exec.currentContext.ParentContext
 			c.logger.Debug("[Merge] merging to parent context with expression: %s", merge.SourceRule)
 		case MergeTargetNamed:
 			var ok bool
-			targetCtx, ok = exec.contextMap[merge.TargetName]
+			targetCtx, ok = exec.contexts.get(merge.TargetName)
 			if !ok {
 				return fmt.Errorf("context '%s' not found", merge.TargetName)
 			}
@@ -959,28 +1760,18 @@ func (c *ApiCrawler) performMerge(exec *stepExecution, result any, templateCtx m
 		}
 		targetCtx.Data = updated
 		mergeRule = merge.SourceRule
+		c.checkpoint.merged(exec, targetCtx, page, &CheckpointMerge{Result: result, Vars: mergeVars(&exec.compiledStep.MergeScope, templateCtx)})
 
 	} else {
 		// Default merge (shallow merge for maps/arrays) - no explicit rule
 		c.logger.Debug("[Merge] default merge")
 
-		switch data := exec.currentContext.Data.(type) {
-		case []interface{}:
-			if resultArr, ok := result.([]interface{}); ok {
-				exec.currentContext.Data = append(data, resultArr...)
-			} else {
-				exec.currentContext.Data = result
-			}
-		case map[string]interface{}:
-			if resultMap, ok := result.(map[string]interface{}); ok {
-				for k, v := range resultMap {
-					data[k] = v
-				}
-			} else {
-				exec.currentContext.Data = result
-			}
-		default:
-			exec.currentContext.Data = result
+		// A null result (e.g. a response treated as empty) leaves the context untouched
+		if result == nil {
+			c.logger.Debug("[Merge] empty result - nothing to merge")
+		} else {
+			exec.currentContext.Data = defaultMerge(exec.currentContext.Data, result)
+			c.checkpoint.merged(exec, exec.currentContext, page, &CheckpointMerge{Result: result})
 		}
 	}
 
@@ -993,13 +1784,54 @@ func (c *ApiCrawler) performMerge(exec *stepExecution, result any, templateCtx m
 			MergeRule:           mergeRule,
 			TargetContextBefore: dataBefore,
 			TargetContextAfter:  dataAfter,
-			ContextMap:          exec.contextMap,
+			ContextMap:          exec.contexts.toMap(),
 		})
 	}
 
 	return nil
 }
 
+// defaultMerge merges result into data when a step has no merge rule: arrays
+// are appended, maps are merged shallowly and anything else replaces data
+func defaultMerge(data any, result any) any {
+	if result == nil {
+		return data
+	}
+	switch data := data.(type) {
+	case []interface{}:
+		if resultArr, ok := result.([]interface{}); ok {
+			return append(data, resultArr...)
+		}
+	case map[string]interface{}:
+		if resultMap, ok := result.(map[string]interface{}); ok {
+			for k, v := range resultMap {
+				data[k] = v
+			}
+			return data
+		}
+	}
+	return result
+}
+
+// takeStreamData detaches the accumulated array data of the current context of exec
+// for streaming, leaving an empty array behind. Non-array data is left untouched.
+// Thread-safe: the read and reset happen under mergeMutex so parallel iterations
+// sharing the context neither lose nor duplicate items.
+func (c *ApiCrawler) takeStreamData(exec *stepExecution, page int) []interface{} {
+	c.mergeMutex.Lock()
+	defer c.mergeMutex.Unlock()
+
+	target := exec.currentContext
+	arrayData, ok := target.Data.([]interface{})
+	if !ok {
+		return nil
+	}
+	target.Data = []interface{}{}
+	// A resumed run must not stream the data again
+	c.checkpoint.merged(exec, target, page, &CheckpointMerge{Take: true})
+	return arrayData
+}
+
 // prepareHTTPRequest builds an HTTP request from the context and pagination parameters
 func (c *ApiCrawler) prepareHTTPRequest(ctx httpRequestContext, templateCtx map[string]any) (*http.Request, *url.URL, map[string]any, error) {
 	var urlObj *url.URL
@@ -1090,7 +1922,7 @@ func (c *ApiCrawler) prepareHTTPRequest(ctx httpRequestContext, templateCtx map[
 		return nil, nil, nil, fmt.Errorf("error creating HTTP request: %w", err)
 	}
 
-	// Apply headers (priority: global < request-specific)
+	// Apply headers (priority: global < request-specific < pagination)
 	// Use direct map assignment to preserve exact header casing (Set() canonicalizes)
 	// Use pre-compiled global headers
 	expandedGlobalHeaders, err := c.CompiledConfig.ExecuteGlobalHeaders(templateCtx)
@@ -1124,6 +1956,11 @@ func (c *ApiCrawler) prepareHTTPRequest(ctx httpRequestContext, templateCtx map[
 		// If header was compiled, it was already set above
 	}
 
+	// Pagination header params (e.g. Range or cursor headers) override configured headers
+	for k, v := range ctx.headerParams {
+		req.Header[k] = []string{v}
+	}
+
 	// Set Content-Type header if body is present
 	if len(mergedBody) > 0 && contentType != "" {
 		req.Header.Set("Content-Type", contentType)
@@ -1137,59 +1974,38 @@ func (c *ApiCrawler) prepareHTTPRequest(ctx httpRequestContext, templateCtx map[
 	return req, urlObj, mergedBody, nil
 }
 
-func childMapWith(base map[string]*Context, currentCotnext *Context, key string, value interface{}) map[string]*Context {
-	newMap := make(map[string]*Context, len(base)+1)
-	for k, v := range base {
-		newMap[k] = v
-	}
-	newMap[key] = &Context{
+// withChildContext binds a new child context of currentContext under key for a
+// forEach iteration and returns it with the extended chain
+func withChildContext(contexts *contextChain, currentContext *Context, key string, value interface{}) (*contextChain, *Context) {
+	child := &Context{
 		Data:          value,
-		ParentContext: currentCotnext.key,
+		ParentContext: currentContext.key,
 		key:           key,
-		depth:         currentCotnext.depth + 1,
+		depth:         currentContext.depth + 1,
 	}
-	return newMap
+	return contexts.with(key, child), child
 }
 
-// childMapWithOverlay creates an overlay context for forValues.
-// Unlike childMapWith, this:
+// withOverlayContext binds an overlay context for forValues.
+// Unlike withChildContext, this:
 // - Assigns the value directly (no wrapper)
 // - Keeps the same parent reference (overlay, not child)
 // - Nested steps operate on the PARENT context but have access to the value via 'as' key
-func childMapWithOverlay(base map[string]*Context, currentContext *Context, key string, value interface{}) map[string]*Context {
-	newMap := make(map[string]*Context, len(base)+1)
-	for k, v := range base {
-		newMap[k] = v
-	}
+func withOverlayContext(contexts *contextChain, currentContext *Context, key string, value interface{}) *contextChain {
 	// Create overlay context - same depth as parent, parent points to parent's parent
-	newMap[key] = &Context{
+	return contexts.with(key, &Context{
 		Data:          value, // Value assigned directly, no wrapper
 		ParentContext: currentContext.ParentContext,
 		key:           key,
 		depth:         currentContext.depth, // Same depth - it's an overlay, not a child
-	}
-	return newMap
-}
-
-// clonedContextResult holds the result of cloning a context, including
-// the new context map and the key where the working context was placed
-type clonedContextResult struct {
-	contextMap map[string]*Context
-	workingKey string // The key where the cloned/working context is stored
+	})
 }
 
-// childMapWithClonedContext creates a new context map with a cloned working context.
-// If the current context is a canonical context (exists in canonicalMap), the clone
-// is stored under a unique working key to avoid shadowing the original.
+// withWorkingContext binds a working context holding the response data of a request.
+// If the current context is a canonical context (exists in canonicalMap), the working
+// context is bound under a unique key to avoid shadowing the original.
 // This ensures that mergeWithContext can always find the original canonical contexts.
-func childMapWithClonedContext(base map[string]*Context, currentContext *Context, value interface{}, canonicalMap map[string]*Context) clonedContextResult {
-	newMap := make(map[string]*Context, len(base)+2)
-
-	// Copy all existing contexts
-	for k, v := range base {
-		newMap[k] = v
-	}
-
+func withWorkingContext(contexts *contextChain, currentContext *Context, value interface{}, canonicalMap map[string]*Context) (*contextChain, *Context) {
 	// Determine the working key - if current context is canonical, use a unique key
 	workingKey := currentContext.key
 	if _, isCanonical := canonicalMap[currentContext.key]; isCanonical {
@@ -1198,49 +2014,74 @@ func childMapWithClonedContext(base map[string]*Context, currentContext *Context
 	}
 
 	// Create the working context with the response data
-	newMap[workingKey] = &Context{
+	working := &Context{
 		Data:          value,
 		ParentContext: currentContext.ParentContext, // Parent is the same as the original context
 		key:           workingKey,
 		depth:         currentContext.depth + 1,
 	}
+	return contexts.with(workingKey, working), working
+}
 
-	return clonedContextResult{
-		contextMap: newMap,
-		workingKey: workingKey,
+// stepTemplateScope returns the part of the template context read by the
+// templates, transformer and merge rule of a step (including the global
+// headers). While profiling, the whole context is built so it can be shown.
+func (c *ApiCrawler) stepTemplateScope(exec *stepExecution) *ContextScope {
+	if exec.compiledStep == nil || c.profiler.Enabled() {
+		return fullScope
+	}
+	scope := &exec.compiledStep.TemplateScope
+	if global := c.CompiledConfig.GlobalHeaderScope; global.All || len(global.Keys) > 0 {
+		combined := ContextScope{All: scope.All, Keys: append([]string(nil), scope.Keys...)}
+		combined.add(global)
+		scope = &combined
 	}
+	return scope
 }
 
-// contextMapToTemplate creates a template context from the context map.
+// contextMapToTemplate creates a template context from the visible contexts,
+// holding only the top-level keys of scope (nil scope = everything).
 // Thread-safe: acquires mergeMutex to prevent races with concurrent merge operations.
 // Also normalizes numeric values to avoid scientific notation in templates.
-func (c *ApiCrawler) contextMapToTemplate(base map[string]*Context, vars map[string]any) map[string]interface{} {
+func (c *ApiCrawler) contextMapToTemplate(contexts *contextChain, vars map[string]any, scope *ContextScope) map[string]interface{} {
+	if scope != nil && !scope.All {
+		return c.scopedTemplateContext(contexts, vars, scope.Keys)
+	}
+
 	// Acquire lock to prevent races with merge operations
 	c.mergeMutex.Lock()
 
 	result := make(map[string]interface{})
 
 	// First pass: add all contexts by their key (deep copy + normalize in one pass)
-	for k, ctx := range base {
+	var responses []*Context
+	contexts.each(func(k string, ctx *Context) {
 		result[k] = deepCopyAndNormalizeValue(ctx.Data)
-	}
+		if len(k) > 10 && k[:10] == "_response_" {
+			responses = append(responses, ctx)
+		}
+	})
 
 	// Second pass: spread map data from special contexts into top level
 	// This allows templates to access fields directly (e.g., {{ .FacilityId }})
-	// Priority: _response_* contexts first (most specific), then root
-	for k, ctx := range base {
+	// Priority: _response_* contexts first (most specific), then root.
+	// responses are newest first: a field set by a newer one is not overwritten.
+	spread := make(map[string]struct{})
+	for _, ctx := range responses {
 		// Spread _response_* context data (working contexts from request steps)
-		if len(k) > 10 && k[:10] == "_response_" {
-			if dataMap, ok := ctx.Data.(map[string]interface{}); ok {
-				for field, v := range dataMap {
-					result[field] = deepCopyAndNormalizeValue(v)
+		if dataMap, ok := ctx.Data.(map[string]interface{}); ok {
+			for field, v := range dataMap {
+				if _, done := spread[field]; done {
+					continue
 				}
+				spread[field] = struct{}{}
+				result[field] = deepCopyAndNormalizeValue(v)
 			}
 		}
 	}
 
 	// Finally spread root context data (lowest priority, won't overwrite)
-	if rootCtx, ok := base["root"]; ok {
+	if rootCtx, ok := contexts.get("root"); ok {
 		if rootMap, ok := rootCtx.Data.(map[string]interface{}); ok {
 			for k, v := range rootMap {
 				if _, exists := result[k]; !exists {
@@ -1263,6 +2104,65 @@ func (c *ApiCrawler) contextMapToTemplate(base map[string]*Context, vars map[str
 	return result
 }
 
+// scopedTemplateContext builds the template context for the given keys only,
+// so its cost depends on the data a step reads rather than on the size of the
+// context map. Keys resolve with the same priority as in contextMapToTemplate:
+// runtime variables, then _response_* data, then contexts by key, then root data.
+func (c *ApiCrawler) scopedTemplateContext(contexts *contextChain, vars map[string]any, keys []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return result
+	}
+
+	var responses []*Context
+	contexts.each(func(k string, ctx *Context) {
+		if len(k) > 10 && k[:10] == "_response_" {
+			responses = append(responses, ctx)
+		}
+	})
+
+	c.mergeMutex.Lock()
+	for _, key := range keys {
+		if _, isVar := vars[key]; isVar {
+			continue
+		}
+		if v, ok := lookupTemplateKey(contexts, responses, key); ok {
+			result[key] = deepCopyAndNormalizeValue(v)
+		}
+	}
+	c.mergeMutex.Unlock()
+
+	for _, key := range keys {
+		if v, ok := vars[key]; ok {
+			result[key] = deepCopyAndNormalizeValue(v)
+		}
+	}
+	return result
+}
+
+// lookupTemplateKey resolves a top-level template context key in the visible
+// contexts, given the visible _response_* working contexts
+func lookupTemplateKey(contexts *contextChain, responses []*Context, key string) (any, bool) {
+	for _, ctx := range responses {
+		if dataMap, ok := ctx.Data.(map[string]interface{}); ok {
+			if v, exists := dataMap[key]; exists {
+				return v, true
+			}
+		}
+	}
+	if ctx, ok := contexts.get(key); ok {
+		return ctx.Data, true
+	}
+	if rootCtx, ok := contexts.get("root"); ok {
+		if rootMap, ok := rootCtx.Data.(map[string]interface{}); ok {
+			if v, exists := rootMap[key]; exists {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // deepCopyAndNormalizeValue recursively deep copies a value while normalizing floats.
 // Used for template rendering to avoid scientific notation (e.g., 100024999 instead of 1.00025e+08)
 func deepCopyAndNormalizeValue(v any) any {
@@ -8,11 +8,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	crawler_testing "github.com/noi-techpark/go-silky/testing"
 	"github.com/stretchr/testify/assert"
@@ -309,6 +317,7 @@ func TestParallelRateLimited(t *testing.T) {
 
 func TestParallelNoopMerge(t *testing.T) {
 	mockTransport := crawler_testing.NewMockRoundTripper(map[string]string{
+		"https://api.example.com/init":    "testdata/crawler/parallel/item_1.json", // body is replaced by resultTransformer
 		"https://api.example.com/items/1": "testdata/crawler/parallel/item_1.json",
 		"https://api.example.com/items/2": "testdata/crawler/parallel/item_2.json",
 		"https://api.example.com/items/3": "testdata/crawler/parallel/item_3.json",
@@ -454,6 +463,360 @@ func TestParallelMultiRootParallel(t *testing.T) {
 	assert.True(t, productIds[102], "Should have product with id 102")
 }
 
+func TestParallelForValues(t *testing.T) {
+	mockTransport := crawler_testing.NewMockRoundTripper(map[string]string{
+		"https://api.example.com/items/1": "testdata/crawler/parallel/item_1.json",
+		"https://api.example.com/items/2": "testdata/crawler/parallel/item_2.json",
+		"https://api.example.com/items/3": "testdata/crawler/parallel/item_3.json",
+		"https://api.example.com/items/4": "testdata/crawler/parallel/item_4.json",
+		"https://api.example.com/items/5": "testdata/crawler/parallel/item_5.json",
+	})
+
+	// Track how many requests are in flight at the same time
+	var inFlight, peak int32
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if current <= p || atomic.CompareAndSwapInt32(&peak, p, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}
+
+	craw, validationErrors, err := NewApiCrawler("testdata/crawler/parallel/forvalues.yaml")
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+
+	resultMap, ok := craw.GetData().(map[string]interface{})
+	require.True(t, ok, "Result should be a map")
+	require.Len(t, resultMap, 5, "Every iteration should merge into the shared root context")
+	for i := 1; i <= 5; i++ {
+		item := resultMap[fmt.Sprintf("item_%d", i)].(map[string]interface{})
+		assert.Equal(t, float64(i), item["id"])
+	}
+
+	assert.Greater(t, atomic.LoadInt32(&peak), int32(1), "iterations should run concurrently")
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(3), "maxConcurrency should be respected")
+}
+
+func TestParallelForEachBoundedWorkers(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        parallelism:
+          maxConcurrency: 4
+        steps:
+          - type: request
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	const numItems = 300
+	list := make([]interface{}, numItems)
+	responses := map[string]interface{}{"https://api.example.com/items": list}
+	for i := 0; i < numItems; i++ {
+		list[i] = map[string]interface{}{"id": i}
+		responses[fmt.Sprintf("https://api.example.com/items/%d", i)] = map[string]interface{}{"n": i}
+	}
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(responses)
+
+	// Shuffle completion order and track the peak number of goroutines
+	var peak int32
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if n := int32(runtime.NumGoroutine()); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		time.Sleep(time.Duration(len(req.URL.Path)%3) * time.Millisecond)
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	baseline := runtime.NumGoroutine()
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	data := craw.GetData().([]interface{})
+	require.Len(t, data, numItems)
+	for i, item := range data {
+		details := item.(map[string]interface{})["details"].(map[string]interface{})
+		assert.Equal(t, float64(i), details["n"], "results should keep item order")
+	}
+	assert.Less(t, int(atomic.LoadInt32(&peak)), baseline+20, "goroutines should be bounded by maxConcurrency")
+}
+
+// slowTransport answers every request after delay unless the request is
+// cancelled first. Paths listed in failing get an immediate 500.
+type slowTransport struct {
+	delay    time.Duration
+	failing  map[string]bool
+	started  int32
+	inFlight int32
+}
+
+func (s *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&s.started, 1)
+	atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+
+	status := http.StatusOK
+	if s.failing[req.URL.Path] {
+		status = http.StatusInternalServerError
+	} else {
+		select {
+		case <-time.After(s.delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"path": "` + req.URL.Path + `"}`)),
+		Request:    req,
+	}, nil
+}
+
+func writeParallelForValuesConfig(t *testing.T, errorMode string) string {
+	configContent := fmt.Sprintf(`
+rootContext: []
+steps:
+  - type: forValues
+    values: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12]
+    as: id
+    parallelism:
+      maxConcurrency: 3
+      errorMode: %s
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/items/{{ .id }}
+          method: GET
+        mergeOn: . + [$res]
+`, errorMode)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	return configPath
+}
+
+func TestParallelFailFastCancelsSiblings(t *testing.T) {
+	transport := &slowTransport{delay: 5 * time.Second, failing: map[string]bool{"/items/1": true}}
+
+	craw, _, err := NewApiCrawler(writeParallelForValuesConfig(t, "failFast"))
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: transport})
+
+	start := time.Now()
+	err = craw.Run(context.TODO(), nil)
+	require.NotNil(t, err)
+
+	var statusErr *HTTPStatusError
+	assert.True(t, errors.As(err, &statusErr), "the failing iteration's error should be returned, got %v", err)
+	assert.Less(t, time.Since(start), 2*time.Second, "running siblings should be cancelled")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&transport.inFlight), "no request may outlive Run")
+	assert.Less(t, atomic.LoadInt32(&transport.started), int32(12), "no new iterations should start after the failure")
+}
+
+func TestParallelCollectErrors(t *testing.T) {
+	transport := &slowTransport{failing: map[string]bool{"/items/2": true, "/items/5": true}}
+
+	craw, _, err := NewApiCrawler(writeParallelForValuesConfig(t, "collectErrors"))
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: transport})
+
+	err = craw.Run(context.TODO(), nil)
+	require.NotNil(t, err)
+
+	assert.Equal(t, int32(12), atomic.LoadInt32(&transport.started), "every iteration should run")
+	assert.Contains(t, err.Error(), "iteration 1: ")
+	assert.Contains(t, err.Error(), "iteration 4: ")
+	var statusErr *HTTPStatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Len(t, craw.GetData(), 10, "successful iterations should still be merged")
+}
+
+func TestValidateParallelismErrorMode(t *testing.T) {
+	errs := validateParallelism(ParallelismConfig{ErrorMode: "ignore"}, "steps[0].parallelism")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "steps[0].parallelism.errorMode", errs[0].Location)
+}
+
+func TestHeaderPagination(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Range pages"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        params:
+          - name: Range
+            location: header
+            type: int
+            default: "0"
+            increment: "+ 2"
+            template: "items={{ .Range }}-{{ add .Range 1 }}"
+        stopOn:
+          - type: responseBody
+            expression: length < 2
+  - type: request
+    name: "Cursor pages"
+    request:
+      url: https://api.example.com/events
+      method: GET
+      pagination:
+        params:
+          - name: X-Cursor
+            location: header
+            type: dynamic
+            source: "header:X-Next-Cursor"
+        stopOn:
+          - type: responseBody
+            expression: .last == true
+    resultTransformer: .events
+    mergeOn: . + $res
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	items := []string{"a", "b", "c", "d", "e"}
+	events := map[string]string{
+		"":   `{"events": ["e1", "e2"]}`,
+		"c2": `{"events": ["e3"]}`,
+		"c3": `{"events": ["e4"], "last": true}`,
+	}
+	nextCursor := map[string]string{"": "c2", "c2": "c3"}
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items":  []interface{}{},
+		"https://api.example.com/events": map[string]interface{}{},
+	})
+	var mu sync.Mutex
+	var ranges, cursors []string
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/items":
+			rangeHeader := req.Header.Get("Range")
+			ranges = append(ranges, rangeHeader)
+			var from, to int
+			fmt.Sscanf(rangeHeader, "items=%d-%d", &from, &to)
+			page := []string{}
+			for i := from; i <= to && i < len(items); i++ {
+				page = append(page, items[i])
+			}
+			body, _ := json.Marshal(page)
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+		case "/events":
+			cursor := req.Header.Get("X-Cursor")
+			cursors = append(cursors, cursor)
+			if next, ok := nextCursor[cursor]; ok {
+				resp.Header.Set("X-Next-Cursor", next)
+			}
+			resp.Body = io.NopCloser(strings.NewReader(events[cursor]))
+		}
+	}
+
+	craw, validationErrors, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	profiler := craw.EnableProfiler()
+	var headerParams []map[string]string
+	profilerDone := make(chan struct{})
+	go func() {
+		defer close(profilerDone)
+		for event := range profiler {
+			if event.Type == EVENT_URL_COMPOSITION {
+				state := event.Data["paginationState"].(map[string]any)
+				headerParams = append(headerParams, state["headerParams"].(map[string]string))
+			}
+		}
+	}()
+
+	err = craw.Run(context.TODO(), nil)
+	close(profiler)
+	<-profilerDone
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"items=0-1", "items=2-3", "items=4-5"}, ranges)
+	assert.Equal(t, []string{"", "c2", "c3"}, cursors)
+	assert.Equal(t, []interface{}{"a", "b", "c", "d", "e", "e1", "e2", "e3", "e4"}, craw.GetData())
+	require.Len(t, headerParams, 6)
+	assert.Equal(t, map[string]string{"Range": "items=2-3"}, headerParams[1], "URL_COMPOSITION should show pagination headers")
+	assert.Equal(t, map[string]string{"X-Cursor": "c2"}, headerParams[4])
+}
+
+func TestLinkHeaderPagination(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/v1/items?page=1
+      method: GET
+      pagination:
+        nextPageUrlSelector: "link:next"
+    mergeOn: . + $res
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	pages := map[string]string{"1": `["a", "b"]`, "2": `["c", "d"]`, "3": `["e"]`}
+	links := map[string]string{
+		"1": `</v1/items?page=2>; rel="next", </v1/items?page=3>; rel="last"`,
+		"2": `<https://api.example.com/v1/items?page=1>; rel="prev", <?page=3>; rel="next last"`,
+		"3": `</v1/items?page=2>; rel="prev"`,
+	}
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/v1/items": []interface{}{},
+	})
+	var requested []string
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		page := req.URL.Query().Get("page")
+		requested = append(requested, req.URL.String())
+		resp.Header.Set("Link", links[page])
+		resp.Body = io.NopCloser(strings.NewReader(pages[page]))
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Equal(t, []string{
+		"https://api.example.com/v1/items?page=1",
+		"https://api.example.com/v1/items?page=2",
+		"https://api.example.com/v1/items?page=3",
+	}, requested, "relative links are resolved against the request URL")
+	assert.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, craw.GetData())
+}
+
 func TestPostJSONBody(t *testing.T) {
 	mockTransport, err := crawler_testing.NewMockRoundTripperFromYAML("testdata/crawler/post_json_body/mocks.yaml")
 	require.Nil(t, err)
@@ -727,6 +1090,8 @@ func TestAuthCustomBodyToQuery(t *testing.T) {
 		"https://api.provider.com/v1/sensors?api_key=ak_live_1234567890abcdef":            "testdata/crawler/auth_custom_body_to_query/sensors_response.json",
 		"https://api.provider.com/v1/sensors/1/readings?api_key=ak_live_1234567890abcdef": "testdata/crawler/auth_custom_body_to_query/readings_response.json",
 		"https://api.provider.com/v1/sensors/2/readings?api_key=ak_live_1234567890abcdef": "testdata/crawler/auth_custom_body_to_query/readings_response.json",
+		"https://api.provider.com/v1/sensors/sensor-101/readings?api_key=ak_live_1234567890abcdef": "testdata/crawler/auth_custom_body_to_query/readings_response.json",
+		"https://api.provider.com/v1/sensors/sensor-102/readings?api_key=ak_live_1234567890abcdef": "testdata/crawler/auth_custom_body_to_query/readings_response.json",
 	})
 
 	craw, _, _ := NewApiCrawler("testdata/crawler/auth_custom_body_to_query.yaml")
@@ -746,6 +1111,8 @@ func TestAuthMixedOverride(t *testing.T) {
 	mockTransport := crawler_testing.NewMockRoundTripper(map[string]string{
 		"https://api.example.com/public/stats":       "testdata/crawler/auth_mixed_override/stats_response.json",
 		"https://admin.example.com/internal/reports": "testdata/crawler/auth_mixed_override/reports_response.json",
+		"https://api.example.com/reports/rpt-1/details": "testdata/crawler/auth_mixed_override/details_response.json",
+		"https://api.example.com/reports/rpt-2/details": "testdata/crawler/auth_mixed_override/details_response.json",
 	})
 
 	craw, _, _ := NewApiCrawler("testdata/crawler/auth_mixed_override.yaml")
@@ -1488,3 +1855,599 @@ steps:
 	assert.Equal(t, "ok", resultMap["status"])
 	assert.Equal(t, float64(42), resultMap["count"])
 }
+
+func TestStepWhenCondition(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            name: "Fetch details for active items"
+            when: .active
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res
+          - type: request
+            name: "Fetch archive for inactive items"
+            when: (.active | not) and $ctx.withArchive
+            request:
+              url: https://api.example.com/archive/{{ .item.id }}
+              method: GET
+            mergeOn: .archive = $res
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1, "active": true},
+			map[string]interface{}{"id": 2, "active": false},
+		},
+		"https://api.example.com/items/1":   map[string]interface{}{"name": "one"},
+		"https://api.example.com/archive/2": map[string]interface{}{"name": "two"},
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	profiler := craw.EnableProfiler()
+	var conditions []StepProfilerData
+	done := make(chan struct{})
+	go func() {
+		for event := range profiler {
+			if event.Type == EVENT_STEP_CONDITION {
+				conditions = append(conditions, event)
+			}
+		}
+		close(done)
+	}()
+
+	err = craw.Run(context.TODO(), map[string]any{"withArchive": true})
+	close(profiler)
+	<-done
+	require.Nil(t, err)
+	assert.Empty(t, mockTransport.Errors, "only requests whose condition holds should be sent")
+
+	data := craw.GetData().([]interface{})
+	require.Len(t, data, 2)
+	first := data[0].(map[string]interface{})
+	second := data[1].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"name": "one"}, first["details"])
+	assert.NotContains(t, first, "archive")
+	assert.Equal(t, map[string]interface{}{"name": "two"}, second["archive"])
+	assert.NotContains(t, second, "details")
+
+	// Two items x two conditional steps
+	require.Len(t, conditions, 4)
+	skipped := 0
+	for _, event := range conditions {
+		if event.Data["skipped"] == true {
+			skipped++
+		}
+	}
+	assert.Equal(t, 2, skipped)
+}
+
+func TestStepWhenWithoutCompiledStep(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    when: $ctx.enabled
+    request:
+      url: https://api.example.com/items
+      method: GET
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	var requests int
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(clientFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`[]`)), Request: req}, nil
+	}))
+	craw.CompiledConfig = nil
+
+	err = craw.Run(context.TODO(), map[string]any{"enabled": false})
+	assert.ErrorContains(t, err, "when condition")
+	assert.Zero(t, requests, "the step does not run without its condition")
+}
+
+func TestPaginationTotalParallel(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Export items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "1"
+            increment: "+ 1"
+        total:
+          selector: .totalPages
+        parallelism:
+          maxConcurrency: 3
+    resultTransformer: .items
+    mergeOn: . + $res
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	const totalPages = 7
+	var mu sync.Mutex
+	var requested []int
+	inFlight, peak := 0, 0
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		page, err := strconv.Atoi(req.URL.Query().Get("page"))
+		require.Nil(t, err)
+		mu.Lock()
+		requested = append(requested, page)
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		// Later pages answer first
+		time.Sleep(time.Duration(totalPages-page) * 5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		body, _ := json.Marshal(map[string]any{"totalPages": totalPages, "items": []int{page*10 + 1, page*10 + 2}})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	craw, validationErrors, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+	craw.SetClient(client)
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, 1, requested[0], "the first page is fetched alone")
+	assert.ElementsMatch(t, []int{1, 2, 3, 4, 5, 6, 7}, requested)
+	assert.Greater(t, peak, 1, "remaining pages are fetched concurrently")
+	assert.LessOrEqual(t, peak, 3)
+	expected := []interface{}{}
+	for page := 1; page <= totalPages; page++ {
+		expected = append(expected, float64(page*10+1), float64(page*10+2))
+	}
+	assert.Equal(t, expected, craw.GetData(), "pages are merged in page order")
+}
+
+func TestPaginationTotalItems(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        params:
+          - name: offset
+            location: query
+            type: int
+            default: "0"
+            increment: "+ 2"
+        total:
+          selector: .meta.total
+          unit: items
+          pageSize: 2
+    resultTransformer: .items
+    mergeOn: . + $res
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	items := []string{"a", "b", "c", "d", "e"}
+	var mu sync.Mutex
+	var offsets []string
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		mu.Lock()
+		offsets = append(offsets, req.URL.Query().Get("offset"))
+		mu.Unlock()
+		body, _ := json.Marshal(map[string]any{"meta": map[string]any{"total": len(items)}, "items": items[offset:min(offset+2, len(items))]})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(client)
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Equal(t, []string{"0", "2", "4"}, offsets, "without parallelism the remaining pages are fetched one at a time")
+	assert.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, craw.GetData())
+}
+
+func TestPaginationTotalFailFast(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "0"
+            increment: "+ 1"
+        total:
+          selector: .pages
+        parallelism:
+          maxConcurrency: 2
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	var calls int32
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		status := http.StatusOK
+		if req.URL.Query().Get("page") == "2" {
+			status = http.StatusInternalServerError
+		} else {
+			select {
+			case <-time.After(50 * time.Millisecond):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"pages": 100}`)),
+			Request:    req,
+		}, nil
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(client)
+
+	err = craw.Run(context.TODO(), nil)
+	var statusErr *HTTPStatusError
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	assert.Less(t, int(atomic.LoadInt32(&calls)), 10, "a failed page cancels the remaining ones")
+}
+
+func TestValidatePaginationTotal(t *testing.T) {
+	errs := validatePagination(Pagination{
+		Params: []Param{
+			{Name: "page", Location: "query", Type: "int", Default: "1", Increment: "+ 1"},
+			{Name: "cursor", Location: "query", Type: "dynamic", Source: "body:.next"},
+		},
+		Total:       &PaginationTotal{Unit: "items"},
+		Parallelism: &ParallelismConfig{ErrorMode: "ignore"},
+	}, "pagination")
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"pagination.total.selector",
+		"pagination.total.pageSize",
+		"pagination.total",
+		"pagination.parallelism.errorMode",
+	}, locations)
+
+	errs = validatePagination(Pagination{
+		Params:      []Param{{Name: "page", Location: "query", Type: "int", Default: "1"}},
+		StopOn:      []StopCondition{{Type: "pageNum", Value: 3}},
+		Parallelism: &ParallelismConfig{MaxConcurrency: 2},
+	}, "pagination")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "pagination.parallelism", errs[0].Location)
+}
+
+func TestEmitFromNestedSteps(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            emit: '{id: $ctx.item.id, owner: .owner}'
+            noopMerge: true
+          - type: emit
+            when: .tags
+            emit: '.tags[] | {tag: .}'
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		body := `[{"id": 1, "tags": ["a", "b"]}, {"id": 2}]`
+		if req.URL.Path != "/items" {
+			body = fmt.Sprintf(`{"owner": "o%s"}`, strings.TrimPrefix(req.URL.Path, "/items/"))
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(client)
+
+	var streamed []any
+	done := make(chan struct{})
+	go func() {
+		for d := range craw.GetDataStream() {
+			streamed = append(streamed, d)
+		}
+		close(done)
+	}()
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	close(craw.GetDataStream())
+	<-done
+
+	out, err := json.Marshal(streamed)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[{"id": 1, "owner": "o1"}, {"tag": "a"}, {"tag": "b"}, {"id": 2, "owner": "o2"}]`, string(out),
+		"only emitted values are streamed, not the data merged into the root context")
+}
+
+func TestEmitFromParallelIterations(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+steps:
+  - type: forValues
+    values: [1, 2, 3]
+    as: id
+    parallelism:
+      maxConcurrency: 3
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/items/{{ .id }}
+          method: GET
+        resultTransformer: '{id: $ctx.id}'
+        noopMerge: true
+        emit: .
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: &peakTransport{delay: 5 * time.Millisecond}})
+
+	var ids []string
+	done := make(chan struct{})
+	go func() {
+		for d := range craw.GetDataStream() {
+			ids = append(ids, fmt.Sprint(d.(map[string]any)["id"]))
+		}
+		close(done)
+	}()
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	close(craw.GetDataStream())
+	<-done
+
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+}
+
+func TestValidateEmit(t *testing.T) {
+	cfg := Config{
+		RootContext: []any{},
+		Steps: []Step{
+			{
+				Type: "forValues", Values: []any{1}, As: "id",
+				Steps: []Step{
+					{Type: "emit", Emit: "."},
+					{Type: "emit", Path: ".items"},
+				},
+			},
+		},
+	}
+	locations := []string{}
+	for _, e := range ValidateConfig(cfg) {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"steps[0].steps[1].emit",
+		"steps[0].steps[1]",
+		"steps[0].steps[0].emit",
+	}, locations)
+
+	cfg.Stream = true
+	cfg.Steps[0].Steps = cfg.Steps[0].Steps[:1]
+	assert.Empty(t, ValidateConfig(cfg))
+
+	cfg.Steps[0].Steps[0].Emit = ".items["
+	_, errs, err := ValidateAndCompile(cfg)
+	require.Nil(t, err)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "compilation", errs[0].Location)
+	assert.Contains(t, errs[0].Message, "emit")
+}
+
+func TestEmitIntoNamedOutputs(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+outputs: [stations, measurements]
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/stations
+      method: GET
+    noopMerge: true
+    emit: '.[] | {id, name}'
+    output: stations
+    steps:
+      - type: forEach
+        path: .
+        as: station
+        steps:
+          - type: request
+            request:
+              url: https://api.example.com/stations/{{ .station.id }}/measurements
+              method: GET
+            noopMerge: true
+            emit: '.[] | {station: $ctx.station.id, value}'
+            output: measurements
+  - type: emit
+    emit: '{source: "example"}'
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		body := `[{"id": "s1", "name": "One"}, {"id": "s2", "name": "Two"}]`
+		if req.URL.Path != "/stations" {
+			body = `[{"value": 1}, {"value": 2}]`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(client)
+	assert.Nil(t, craw.GetOutputStream("unknown"))
+
+	names := []string{"", "stations", "measurements"}
+	received := make([][]any, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		stream := craw.GetOutputStream(name)
+		require.NotNil(t, stream, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range stream {
+				received[i] = append(received[i], d)
+			}
+		}()
+	}
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	for _, name := range names {
+		close(craw.GetOutputStream(name))
+	}
+	wg.Wait()
+
+	for i, expected := range []string{
+		`[{"source": "example"}]`,
+		`[{"id": "s1", "name": "One"}, {"id": "s2", "name": "Two"}]`,
+		`[{"station": "s1", "value": 1}, {"station": "s1", "value": 2}, {"station": "s2", "value": 1}, {"station": "s2", "value": 2}]`,
+	} {
+		out, err := json.Marshal(received[i])
+		require.Nil(t, err)
+		assert.JSONEq(t, expected, string(out), "output %q", names[i])
+	}
+}
+
+func TestValidateOutputs(t *testing.T) {
+	cfg := Config{
+		RootContext: []any{},
+		Outputs:     []string{"stations", "stations", "default", "a/b"},
+		Steps: []Step{
+			{Type: "emit", Emit: ".", Output: "stations"},
+			{Type: "forValues", Values: []any{1}, As: "id", Steps: []Step{
+				{Type: "emit", Emit: ".", Output: "sensors"},
+			}},
+		},
+	}
+	var locations []string
+	for _, e := range ValidateConfig(cfg) {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"outputs",
+		"outputs[1]",
+		"outputs[2]",
+		"outputs[3]",
+		"steps[0].emit",
+		"steps[1].steps[0].emit",
+		"steps[1].steps[0].output",
+	}, locations)
+}
+
+func TestValidateOutputWithoutStreaming(t *testing.T) {
+	request := &RequestConfig{URL: "https://api.example.com", Method: "GET"}
+	cfg := Config{
+		RootContext: []any{},
+		Stream:      true,
+		Outputs:     []string{"items"},
+		Steps: []Step{
+			{Type: "request", Request: request, Output: "items", Steps: []Step{
+				{Type: "forEach", Path: ".", As: "item", Steps: []Step{
+					{Type: "request", Request: request, Output: "items"},
+				}},
+			}},
+		},
+	}
+	var locations []string
+	for _, e := range ValidateConfig(cfg) {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{"steps[0].steps[0].steps[0].output"}, locations,
+		"the top-level request streams, the nested one neither emits nor streams")
+
+	// Once a step emits, only emitting steps send to their output
+	cfg.Steps[0].Steps[0].Steps[0].Emit = "."
+	locations = nil
+	for _, e := range ValidateConfig(cfg) {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{"steps[0].output"}, locations)
+}
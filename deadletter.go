@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"errors"
+)
+
+// Supported values for Step.OnError
+const (
+	OnErrorFail    = "fail"    // abort the crawl (default)
+	OnErrorSkip    = "skip"    // drop the failed iteration and continue
+	OnErrorCollect = "collect" // record the failed iteration as a dead letter and continue
+)
+
+// DeadLetter records a forEach/forValues iteration that failed under onError: collect
+type DeadLetter struct {
+	StepPath string `json:"stepPath"`
+	StepName string `json:"stepName,omitempty"`
+	Index    int    `json:"index"`
+	Item     any    `json:"item"`
+	Error    string `json:"error"`
+	Err      error  `json:"-"` // original error, for errors.Is/errors.As
+}
+
+// isValidOnError reports whether mode is a supported onError value
+func isValidOnError(mode string) bool {
+	switch mode {
+	case "", OnErrorFail, OnErrorSkip, OnErrorCollect:
+		return true
+	}
+	return false
+}
+
+// DeadLetters returns the iterations that failed during the last Run under onError: collect
+func (c *ApiCrawler) DeadLetters() []DeadLetter {
+	c.deadLetterMutex.Lock()
+	defer c.deadLetterMutex.Unlock()
+	return append([]DeadLetter(nil), c.deadLetters...)
+}
+
+// handleIterationError applies the onError mode of a forEach/forValues step to
+// the error of iteration index. It returns nil when the error is absorbed
+// (skip/collect) and the crawl should continue. Cancellation is never absorbed.
+func (c *ApiCrawler) handleIterationError(ctx context.Context, exec *stepExecution, index int, item any, parentID string, err error) error {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	switch exec.step.OnError {
+	case OnErrorSkip:
+		c.logger.Warning("[OnError] Skipping iteration %d of %s: %v", index, exec.stepPath, err)
+		c.profiler.EmitError("Iteration Skipped", parentID, err.Error())
+		return nil
+	case OnErrorCollect:
+		c.logger.Warning("[OnError] Collecting iteration %d of %s: %v", index, exec.stepPath, err)
+		c.profiler.EmitError("Iteration Collected", parentID, err.Error())
+		c.mergeMutex.Lock()
+		item = copyDataSafe(item)
+		c.mergeMutex.Unlock()
+		c.deadLetterMutex.Lock()
+		c.deadLetters = append(c.deadLetters, DeadLetter{
+			StepPath: exec.stepPath,
+			StepName: exec.step.Name,
+			Index:    index,
+			Item:     item,
+			Error:    err.Error(),
+			Err:      err,
+		})
+		c.deadLetterMutex.Unlock()
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDetailsCrawler returns a crawler fetching a list of 3 items and the details
+// of each; the details of item 2 respond with 404
+func newDetailsCrawler(t *testing.T, onError string) (*ApiCrawler, *crawler_testing.MockRoundTripper) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        name: "Each item"
+        path: .
+        as: item
+        onError: ` + onError + `
+        steps:
+          - type: request
+            name: "Fetch details"
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res
+`
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1},
+			map[string]interface{}{"id": 2},
+			map[string]interface{}{"id": 3},
+		},
+		"https://api.example.com/items/1": map[string]interface{}{"name": "one"},
+		"https://api.example.com/items/2": map[string]interface{}{},
+		"https://api.example.com/items/3": map[string]interface{}{"name": "three"},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if req.URL.Path == "/items/2" {
+			resp.StatusCode = http.StatusNotFound
+		}
+	}
+
+	return newTestCrawler(t, configContent, &http.Client{Transport: mockTransport}), mockTransport
+}
+
+func TestOnErrorFailByDefault(t *testing.T) {
+	craw, _ := newDetailsCrawler(t, "fail")
+
+	err := craw.Run(context.TODO(), nil)
+	var statusErr *HTTPStatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Empty(t, craw.DeadLetters())
+}
+
+func TestOnErrorCollect(t *testing.T) {
+	craw, _ := newDetailsCrawler(t, "collect")
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	data := craw.GetData().([]interface{})
+	require.Len(t, data, 3)
+	assert.Equal(t, map[string]interface{}{"name": "one"}, data[0].(map[string]interface{})["details"])
+	assert.Equal(t, map[string]interface{}{"id": float64(2)}, data[1], "the failed item keeps its original data")
+	assert.Equal(t, map[string]interface{}{"name": "three"}, data[2].(map[string]interface{})["details"])
+
+	deadLetters := craw.DeadLetters()
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "steps[0].steps[0]", deadLetters[0].StepPath)
+	assert.Equal(t, "Each item", deadLetters[0].StepName)
+	assert.Equal(t, 1, deadLetters[0].Index)
+	assert.Equal(t, map[string]interface{}{"id": float64(2)}, deadLetters[0].Item)
+	assert.Contains(t, deadLetters[0].Error, "unexpected HTTP status 404")
+	var statusErr *HTTPStatusError
+	assert.True(t, errors.As(deadLetters[0].Err, &statusErr))
+
+	// Dead letters are reset on every Run
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Len(t, craw.DeadLetters(), 1)
+}
+
+func TestOnErrorSkipParallelForValues(t *testing.T) {
+	configContent := `
+rootContext: {}
+steps:
+  - type: forValues
+    values: ["north", "south", "east"]
+    as: region
+    onError: skip
+    parallelism:
+      maxConcurrency: 2
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/regions/{{ .region }}
+          method: GET
+        mergeOn: .[$ctx.region] = $res
+`
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/regions/north": map[string]interface{}{"stations": 3},
+		"https://api.example.com/regions/east":  map[string]interface{}{"stations": 1},
+	})
+	craw := newTestCrawler(t, configContent, &http.Client{Transport: mockTransport})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Equal(t, map[string]interface{}{
+		"north": map[string]interface{}{"stations": float64(3)},
+		"east":  map[string]interface{}{"stations": float64(1)},
+	}, craw.GetData())
+	assert.Empty(t, craw.DeadLetters(), "skip does not record dead letters")
+}
+
+func TestOnErrorNeverSwallowsCancellation(t *testing.T) {
+	craw, mockTransport := newDetailsCrawler(t, "collect")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if req.URL.Path == "/items/1" {
+			cancel()
+		}
+	}
+
+	err := craw.Run(ctx, nil)
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+	assert.Empty(t, craw.DeadLetters())
+}
+
+func TestValidateOnError(t *testing.T) {
+	errs := validateStep(Step{Type: "forEach", Path: ".", As: "item", OnError: "ignore"}, "steps[0]")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "steps[0].onError", errs[0].Location)
+
+	errs = validateStep(Step{
+		Type:    "request",
+		OnError: "skip",
+		Request: &RequestConfig{URL: "https://api.example.com", Method: "GET"},
+	}, "steps[0]")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "steps[0].onError", errs[0].Location)
+}
@@ -0,0 +1,345 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"strings"
+)
+
+// Supported values for Config.NumberMode
+const (
+	NumberModeExact = "exact" // integers beyond float64 precision are kept exact (default)
+	NumberModeFloat = "float" // every JSON number is decoded as float64
+)
+
+// maxExactFloat is the largest integer magnitude float64 holds exactly (2^53)
+const maxExactFloat = 1 << 53
+
+// Supported values for RequestConfig.ResponseFormat
+const (
+	ResponseFormatJSON   = "json"   // single JSON document (default)
+	ResponseFormatXML    = "xml"    // XML document, converted to maps/arrays/strings
+	ResponseFormatCSV    = "csv"    // CSV with a header row, converted to an array of objects
+	ResponseFormatNDJSON = "ndjson" // newline-delimited JSON, converted to an array
+	ResponseFormatText   = "text"   // body as a single string
+	ResponseFormatAuto   = "auto"   // detect from the Content-Type header, falling back to JSON
+)
+
+// Keys used when converting XML elements into maps
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+// utf8BOM is stripped from CSV exports, which often start with one
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// isValidNumberMode reports whether mode is a supported numberMode value
+func isValidNumberMode(mode string) bool {
+	switch mode {
+	case "", NumberModeExact, NumberModeFloat:
+		return true
+	}
+	return false
+}
+
+// isValidResponseFormat reports whether format is a supported responseFormat value
+func isValidResponseFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "", ResponseFormatJSON, ResponseFormatXML, ResponseFormatCSV,
+		ResponseFormatNDJSON, ResponseFormatText, ResponseFormatAuto:
+		return true
+	}
+	return false
+}
+
+// resolveResponseFormat returns the concrete format to decode a response with.
+// An empty format means JSON; "auto" inspects the Content-Type header.
+func resolveResponseFormat(format string, contentType string) string {
+	format = strings.ToLower(format)
+	if format == "" {
+		return ResponseFormatJSON
+	}
+	if format != ResponseFormatAuto {
+		return format
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	switch {
+	case strings.Contains(mediaType, "ndjson"), strings.Contains(mediaType, "jsonl"),
+		strings.Contains(mediaType, "json-seq"):
+		return ResponseFormatNDJSON
+	case strings.Contains(mediaType, "json"):
+		return ResponseFormatJSON
+	case strings.Contains(mediaType, "xml"):
+		return ResponseFormatXML
+	case strings.Contains(mediaType, "csv"):
+		return ResponseFormatCSV
+	case strings.HasPrefix(mediaType, "text/"):
+		return ResponseFormatText
+	}
+	return ResponseFormatJSON
+}
+
+// decodeBody converts a response body into the generic tree (maps, arrays,
+// strings, float64, bool, nil) consumed by jq selectors and transformers.
+// With exact set, JSON integers that float64 cannot hold are int64 or *big.Int.
+func decodeBody(format string, body []byte, exact bool) (any, error) {
+	switch format {
+	case ResponseFormatJSON:
+		return decodeJSON(body, exact)
+	case ResponseFormatXML:
+		return decodeXML(body)
+	case ResponseFormatCSV:
+		return decodeCSV(body)
+	case ResponseFormatNDJSON:
+		return decodeNDJSON(body, exact)
+	case ResponseFormatText:
+		return string(body), nil
+	}
+	return nil, fmt.Errorf("unsupported response format '%s'", format)
+}
+
+// decodeJSON decodes a single JSON document. With exact set, numbers are read
+// with UseNumber and converted by exactNumbers.
+func decodeJSON(data []byte, exact bool) (any, error) {
+	var v any
+	if !exact {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+	return exactNumbers(v), nil
+}
+
+// exactNumbers replaces the json.Number values of a decoded tree in place.
+// Integers within ±2^53 and decimals become float64, as with json.Unmarshal;
+// larger integers become int64, or *big.Int beyond the int64 range.
+func exactNumbers(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		s := val.String()
+		if !strings.ContainsAny(s, ".eE") {
+			if i, err := val.Int64(); err == nil {
+				if i >= -maxExactFloat && i <= maxExactFloat {
+					return float64(i)
+				}
+				return i
+			}
+			if b, ok := new(big.Int).SetString(s, 10); ok {
+				return b
+			}
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]any:
+		for k, x := range val {
+			val[k] = exactNumbers(x)
+		}
+		return val
+	case []any:
+		for i, x := range val {
+			val[i] = exactNumbers(x)
+		}
+		return val
+	}
+	return v
+}
+
+// recordSeparator starts every record of a JSON text sequence (RFC 7464)
+const recordSeparator = 0x1E
+
+// decodeNDJSON decodes one JSON document per line into an array, skipping blank lines.
+// JSON text sequences (application/json-seq) are split at their record separators instead.
+func decodeNDJSON(body []byte, exact bool) (any, error) {
+	if bytes.IndexByte(body, recordSeparator) >= 0 {
+		return decodeJSONSeq(body, exact)
+	}
+
+	result := []any{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		v, err := decodeJSON(text, exact)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		result = append(result, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeJSONSeq decodes the records of a JSON text sequence into an array.
+// A record is the JSON text between two RS bytes and may span several lines.
+func decodeJSONSeq(body []byte, exact bool) (any, error) {
+	result := []any{}
+	for i, record := range bytes.Split(body, []byte{recordSeparator}) {
+		text := bytes.TrimSpace(record)
+		if len(text) == 0 {
+			continue
+		}
+		v, err := decodeJSON(text, exact)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// decodeCSV decodes CSV data with a header row into an array of objects.
+// All values are kept as strings.
+func decodeCSV(body []byte) (any, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, utf8BOM)))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := []any{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			} else {
+				row[name] = nil
+			}
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// xmlNode is an element being built while decoding XML
+type xmlNode struct {
+	name     string
+	fields   map[string]any
+	text     strings.Builder
+	hasChild bool
+}
+
+// decodeXML converts an XML document into a map keyed by the root element name.
+// Attributes become "@name" keys, repeated child elements become arrays and
+// text content is stored under "#text" (or as a plain string for leaf elements).
+func decodeXML(body []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var stack []*xmlNode
+	var root map[string]any
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, fields: make(map[string]any)}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				node.fields[xmlAttrPrefix+attr.Name.Local] = attr.Value
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasChild = true
+			}
+			stack = append(stack, node)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			value := node.value()
+			if len(stack) == 0 {
+				root = map[string]any{node.name: value}
+				continue
+			}
+			parent := stack[len(stack)-1]
+			switch existing := parent.fields[node.name].(type) {
+			case nil:
+				if _, ok := parent.fields[node.name]; ok {
+					parent.fields[node.name] = []any{nil, value}
+				} else {
+					parent.fields[node.name] = value
+				}
+			case []any:
+				parent.fields[node.name] = append(existing, value)
+			default:
+				parent.fields[node.name] = []any{existing, value}
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no root element found")
+	}
+	return root, nil
+}
+
+// value returns the decoded form of a completed element
+func (n *xmlNode) value() any {
+	text := strings.TrimSpace(n.text.String())
+	if len(n.fields) == 0 && !n.hasChild {
+		if text == "" {
+			return nil
+		}
+		return text
+	}
+	if text != "" {
+		n.fields[xmlTextKey] = text
+	}
+	return n.fields
+}
@@ -0,0 +1,249 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveResponseFormat(t *testing.T) {
+	assert.Equal(t, ResponseFormatJSON, resolveResponseFormat("", "text/csv"), "unset format means JSON regardless of Content-Type")
+	assert.Equal(t, ResponseFormatXML, resolveResponseFormat("XML", ""))
+
+	assert.Equal(t, ResponseFormatJSON, resolveResponseFormat("auto", "application/json; charset=utf-8"))
+	assert.Equal(t, ResponseFormatJSON, resolveResponseFormat("auto", "application/vnd.api+json"))
+	assert.Equal(t, ResponseFormatNDJSON, resolveResponseFormat("auto", "application/x-ndjson"))
+	assert.Equal(t, ResponseFormatXML, resolveResponseFormat("auto", "application/atom+xml"))
+	assert.Equal(t, ResponseFormatXML, resolveResponseFormat("auto", "text/xml"))
+	assert.Equal(t, ResponseFormatCSV, resolveResponseFormat("auto", "text/csv; header=present"))
+	assert.Equal(t, ResponseFormatText, resolveResponseFormat("auto", "text/plain"))
+	assert.Equal(t, ResponseFormatJSON, resolveResponseFormat("auto", ""))
+}
+
+func TestDecodeXML(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<stations xmlns="http://example.com/ns" count="2">
+  <station id="1"><name>Bolzano</name><tag>a</tag><tag>b</tag></station>
+  <station id="2"><name>Merano</name><note lang="de">Kurort</note><empty/></station>
+</stations>`
+
+	v, err := decodeBody(ResponseFormatXML, []byte(body), true)
+	require.Nil(t, err)
+
+	expected := map[string]any{
+		"stations": map[string]any{
+			"@count": "2",
+			"station": []any{
+				map[string]any{"@id": "1", "name": "Bolzano", "tag": []any{"a", "b"}},
+				map[string]any{"@id": "2", "name": "Merano", "note": map[string]any{"@lang": "de", "#text": "Kurort"}, "empty": nil},
+			},
+		},
+	}
+	assert.Equal(t, expected, v)
+
+	_, err = decodeBody(ResponseFormatXML, []byte("<broken>"), true)
+	assert.NotNil(t, err)
+}
+
+func TestDecodeCSV(t *testing.T) {
+	body := "\xef\xbb\xbfid,name,value\n1,Alpha,10\n2,\"Beta, Inc\"\n"
+
+	v, err := decodeBody(ResponseFormatCSV, []byte(body), true)
+	require.Nil(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"id": "1", "name": "Alpha", "value": "10"},
+		map[string]any{"id": "2", "name": "Beta, Inc", "value": nil},
+	}, v)
+
+	v, err = decodeBody(ResponseFormatCSV, []byte(""), true)
+	require.Nil(t, err)
+	assert.Equal(t, []any{}, v)
+}
+
+func TestDecodeNDJSON(t *testing.T) {
+	body := "{\"id\": 1}\n\n{\"id\": 2}\r\n"
+
+	v, err := decodeBody(ResponseFormatNDJSON, []byte(body), true)
+	require.Nil(t, err)
+	assert.Equal(t, []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}}, v)
+
+	_, err = decodeBody(ResponseFormatNDJSON, []byte("{\"id\": 1}\nnot json\n"), true)
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "line 2:"))
+}
+
+func TestDecodeJSONSeq(t *testing.T) {
+	body := "\x1e{\"id\": 1}\n\x1e{\n  \"id\": 2\n}\n"
+	assert.Equal(t, ResponseFormatNDJSON, resolveResponseFormat(ResponseFormatAuto, "application/json-seq"))
+
+	v, err := decodeBody(ResponseFormatNDJSON, []byte(body), true)
+	require.Nil(t, err)
+	assert.Equal(t, []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}}, v)
+
+	_, err = decodeBody(ResponseFormatNDJSON, []byte("\x1e{\"id\": 1}\n\x1enot json\n"), true)
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "record 2:"))
+}
+
+func TestDecodeText(t *testing.T) {
+	v, err := decodeBody(ResponseFormatText, []byte("OK\n"), true)
+	require.Nil(t, err)
+	assert.Equal(t, "OK\n", v)
+}
+
+func TestRequestXMLWithPagination(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch stations"
+    request:
+      url: https://api.example.com/stations.xml
+      method: GET
+      responseFormat: xml
+      pagination:
+        nextPageUrlSelector: 'body:.stations."@next"'
+    resultTransformer: '[.stations.station] | flatten | map({id: ."@id", name})'
+`
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/stations.xml":        "",
+		"https://api.example.com/stations.xml?page=2": "",
+	})
+	pages := map[string]string{
+		"https://api.example.com/stations.xml": `<stations next="https://api.example.com/stations.xml?page=2">
+  <station id="1"><name>Bolzano</name></station>
+  <station id="2"><name>Merano</name></station>
+</stations>`,
+		"https://api.example.com/stations.xml?page=2": `<stations><station id="3"><name>Bressanone</name></station></stations>`,
+	}
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		body := pages[req.URL.String()]
+		resp.Header.Set("Content-Type", "application/xml")
+		resp.Body = io.NopCloser(strings.NewReader(body))
+	}
+
+	craw := newTestCrawler(t, configContent, &http.Client{Transport: mockTransport})
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": "1", "name": "Bolzano"},
+		map[string]any{"id": "2", "name": "Merano"},
+		map[string]any{"id": "3", "name": "Bressanone"},
+	}, craw.GetData())
+}
+
+func TestRequestAutoFormatCSV(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch export"
+    request:
+      url: https://api.example.com/export
+      method: GET
+      responseFormat: auto
+    resultTransformer: 'map(.value |= tonumber)'
+`
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/export": "",
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		resp.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		resp.Body = io.NopCloser(strings.NewReader("id,value\na,1\nb,2\n"))
+	}
+
+	craw := newTestCrawler(t, configContent, &http.Client{Transport: mockTransport})
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": "a", "value": 1},
+		map[string]any{"id": "b", "value": 2},
+	}, craw.GetData())
+}
+
+func TestDecodeJSONNumbers(t *testing.T) {
+	body := []byte(`{"small": 42, "decimal": 2.5, "exp": 1e3, "id": 9007199254740993, "negative": -1234567890123456789, "huge": 123456789012345678901234567890}`)
+
+	v, err := decodeBody(ResponseFormatJSON, body, true)
+	require.Nil(t, err)
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, map[string]any{
+		"small":    float64(42),
+		"decimal":  2.5,
+		"exp":      float64(1000),
+		"id":       int64(9007199254740993),
+		"negative": int64(-1234567890123456789),
+		"huge":     huge,
+	}, v)
+
+	v, err = decodeBody(ResponseFormatJSON, body, false)
+	require.Nil(t, err)
+	assert.Equal(t, float64(9007199254740992), v.(map[string]any)["id"], "float mode rounds like json.Unmarshal")
+
+	v, err = decodeBody(ResponseFormatNDJSON, []byte("{\"id\": 9007199254740993}\n"), true)
+	require.Nil(t, err)
+	assert.Equal(t, []any{map[string]any{"id": int64(9007199254740993)}}, v)
+
+	_, err = decodeBody(ResponseFormatJSON, []byte(`{"id": 1} {"id": 2}`), true)
+	assert.NotNil(t, err, "trailing data is rejected as with json.Unmarshal")
+}
+
+func TestExactNumbersThroughCrawl(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    resultTransformer: 'map({id, next: (.id + 1)})'
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+            mergeOn: .details = $res
+`
+	var paths []string
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		paths = append(paths, req.URL.Path)
+		if req.URL.Path != "/items" {
+			return `{"owner": 9007199254740993}`
+		}
+		return `[{"id": 1234567890123456789}]`
+	}))
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []string{"/items", "/items/1234567890123456789"}, paths)
+	out, err := json.Marshal(craw.GetData())
+	require.Nil(t, err)
+	assert.JSONEq(t, `[{"id": 1234567890123456789, "next": 1234567890123456790, "details": {"owner": 9007199254740993}}]`, string(out))
+	assert.Contains(t, string(out), "1234567890123456790", "the exact integer is kept in the output")
+}
+
+func TestValidateResponseFormat(t *testing.T) {
+	errs := validateRequest(RequestConfig{
+		URL:            "https://api.example.com",
+		Method:         "GET",
+		ResponseFormat: "yaml",
+	}, "steps[0].request")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "steps[0].request.responseFormat", errs[0].Location)
+}
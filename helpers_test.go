@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// clientFunc adapts a function to the HTTPClient interface
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// jsonResponse returns a 200 response to req with a JSON body
+func jsonResponse(req *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// jsonClient answers every request with the JSON body returned by respond
+func jsonClient(respond func(req *http.Request) string) clientFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(req, respond(req)), nil
+	}
+}
+
+// newTestCrawler writes configContent to a config file in a temporary directory
+// and creates a crawler from it that sends its requests with client. The config
+// must be valid.
+func newTestCrawler(t *testing.T, configContent string, client HTTPClient) *ApiCrawler {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	craw, validationErrors, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+	craw.SetClient(client)
+	return craw
+}
@@ -0,0 +1,256 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// Supported values for WatermarkConfig.Aggregate
+const (
+	WatermarkAggregateMax  = "max"  // highest value seen (default)
+	WatermarkAggregateMin  = "min"  // lowest value seen
+	WatermarkAggregateLast = "last" // last value seen
+)
+
+// IncrementalConfig enables incremental crawling: watermark values extracted
+// from the crawled data are persisted and injected as variables into the next Run.
+type IncrementalConfig struct {
+	StateFile  string            `yaml:"stateFile,omitempty" json:"stateFile,omitempty"` // state file used by the default FileStateStore
+	Watermarks []WatermarkConfig `yaml:"watermarks" json:"watermarks"`
+}
+
+// WatermarkConfig declares a value tracked across runs (e.g. the highest updatedAt)
+type WatermarkConfig struct {
+	Name      string `yaml:"name" json:"name"`                               // template variable name
+	Selector  string `yaml:"selector" json:"selector"`                       // jq selector applied to each streamed entity, or to the final result
+	Aggregate string `yaml:"aggregate,omitempty" json:"aggregate,omitempty"` // max (default), min or last
+	Default   any    `yaml:"default,omitempty" json:"default,omitempty"`     // value used when no state was saved yet
+}
+
+// StateStore persists watermark values between runs
+type StateStore interface {
+	// Load returns the saved values, or nil if there are none
+	Load() (map[string]any, error)
+	// Save replaces the saved values
+	Save(state map[string]any) error
+}
+
+// FileStateStore stores watermark values as a JSON file
+type FileStateStore struct {
+	Path string
+}
+
+// NewFileStateStore creates a state store backed by the given file
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{Path: path}
+}
+
+func (s *FileStateStore) Load() (map[string]any, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state, err := decodeJSON(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", s.Path, err)
+	}
+	if state == nil {
+		return nil, nil
+	}
+	values, ok := state.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid state file %s: not a JSON object", s.Path)
+	}
+	return values, nil
+}
+
+// Save writes the state to a temporary file and renames it, so a crash while
+// saving never loses the previous watermarks.
+func (s *FileStateStore) Save(state map[string]any) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// isValidWatermarkAggregate reports whether aggregate is a supported value
+func isValidWatermarkAggregate(aggregate string) bool {
+	switch strings.ToLower(aggregate) {
+	case "", WatermarkAggregateMax, WatermarkAggregateMin, WatermarkAggregateLast:
+		return true
+	}
+	return false
+}
+
+// compileWatermarks compiles the watermark selectors, in declaration order
+func compileWatermarks(cfg *IncrementalConfig) ([]*CompiledJQ, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	compiled := make([]*CompiledJQ, len(cfg.Watermarks))
+	for i, wm := range cfg.Watermarks {
+		jq, err := compileJQ(wm.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("watermark '%s': %w", wm.Name, err)
+		}
+		compiled[i] = jq
+	}
+	return compiled, nil
+}
+
+// watermarkTracker aggregates watermark values during a Run
+type watermarkTracker struct {
+	config    []WatermarkConfig
+	selectors []*CompiledJQ
+	store     StateStore
+
+	mu       sync.Mutex
+	previous map[string]any // values loaded from the store
+	observed map[string]any // values aggregated during this run
+}
+
+// newWatermarkTracker loads the saved watermark values from the store
+func newWatermarkTracker(cfg *IncrementalConfig, selectors []*CompiledJQ, store StateStore) (*watermarkTracker, error) {
+	previous, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading incremental state: %w", err)
+	}
+	if previous == nil {
+		previous = map[string]any{}
+	}
+	return &watermarkTracker{
+		config:    cfg.Watermarks,
+		selectors: selectors,
+		store:     store,
+		previous:  previous,
+		observed:  map[string]any{},
+	}, nil
+}
+
+// vars returns the runtime variables of a Run: the saved watermarks (or their
+// defaults) overridden by the explicitly passed vars.
+func (t *watermarkTracker) vars(explicit map[string]any) map[string]any {
+	if t == nil {
+		return explicit
+	}
+	result := make(map[string]any, len(t.config)+len(explicit))
+	for _, wm := range t.config {
+		if v, ok := t.previous[wm.Name]; ok {
+			result[wm.Name] = v
+		} else {
+			result[wm.Name] = wm.Default
+		}
+	}
+	for k, v := range explicit {
+		result[k] = v
+	}
+	return result
+}
+
+// observe aggregates the values selected from data. The selectors may normalize
+// numbers in data, so it must be called before data is shared.
+func (t *watermarkTracker) observe(data any) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, wm := range t.config {
+		iter := t.selectors[i].Code.Run(data)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, isErr := v.(error); isErr {
+				return fmt.Errorf("watermark '%s': jq error in '%s': %w", wm.Name, wm.Selector, err)
+			}
+			if v == nil {
+				continue
+			}
+			v = copyDataSafe(v)
+			if current, seen := t.observed[wm.Name]; seen {
+				v = aggregateWatermark(wm.Aggregate, current, v)
+			}
+			t.observed[wm.Name] = v
+		}
+	}
+	return nil
+}
+
+// aggregateWatermark returns the watermark after seeing v, given its current value
+func aggregateWatermark(aggregate string, current, v any) any {
+	switch strings.ToLower(aggregate) {
+	case WatermarkAggregateMin:
+		if gojq.Compare(v, current) < 0 {
+			return v
+		}
+	case WatermarkAggregateLast:
+		return v
+	default:
+		if gojq.Compare(v, current) > 0 {
+			return v
+		}
+	}
+	return current
+}
+
+// commit saves the watermarks after a successful run. Watermarks without any
+// value observed in this run keep their saved value; max and min watermarks
+// only advance, so a run that sees older data cannot move them back.
+func (t *watermarkTracker) commit() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := make(map[string]any, len(t.previous)+len(t.observed))
+	for k, v := range t.previous {
+		state[k] = v
+	}
+	for _, wm := range t.config {
+		v, ok := t.observed[wm.Name]
+		if !ok {
+			continue
+		}
+		if saved, ok := t.previous[wm.Name]; ok {
+			v = aggregateWatermark(wm.Aggregate, saved, v)
+		}
+		state[wm.Name] = v
+	}
+	if err := t.store.Save(state); err != nil {
+		return fmt.Errorf("error saving incremental state: %w", err)
+	}
+	t.previous = state
+	return nil
+}
@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStateStore(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+
+	state, err := store.Load()
+	require.Nil(t, err)
+	assert.Nil(t, state, "missing file means no state")
+
+	require.Nil(t, store.Save(map[string]any{"since": "2024-05-01T10:00:00Z", "lastId": 42}))
+
+	state, err = store.Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{"since": "2024-05-01T10:00:00Z", "lastId": float64(42)}, state)
+}
+
+func TestFileStateStoreExactNumbers(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	require.Nil(t, store.Save(map[string]any{"lastId": int64(1234567890123456789)}))
+
+	state, err := store.Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{"lastId": int64(1234567890123456789)}, state, "large watermarks survive a save/load round trip")
+}
+
+func TestWatermarkAggregates(t *testing.T) {
+	cfg := &IncrementalConfig{Watermarks: []WatermarkConfig{
+		{Name: "newest", Selector: ".[].updatedAt"},
+		{Name: "oldest", Selector: ".[].updatedAt", Aggregate: "min"},
+		{Name: "lastId", Selector: ".[].id", Aggregate: "last"},
+		{Name: "missing", Selector: ".[].deletedAt"},
+	}}
+	selectors, err := compileWatermarks(cfg)
+	require.Nil(t, err)
+
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	require.Nil(t, store.Save(map[string]any{"missing": "kept", "other": true}))
+
+	tracker, err := newWatermarkTracker(cfg, selectors, store)
+	require.Nil(t, err)
+	require.Nil(t, tracker.observe([]any{
+		map[string]any{"id": float64(7), "updatedAt": "2024-05-02"},
+		map[string]any{"id": float64(3), "updatedAt": "2024-05-03"},
+		map[string]any{"id": float64(5), "updatedAt": "2024-05-01"},
+	}))
+	require.Nil(t, tracker.commit())
+
+	state, err := store.Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"newest":  "2024-05-03",
+		"oldest":  "2024-05-01",
+		"lastId":  float64(5),
+		"missing": "kept",
+		"other":   true,
+	}, state, "watermarks without new values keep their saved value")
+}
+
+func TestWatermarksDoNotGoBack(t *testing.T) {
+	cfg := &IncrementalConfig{Watermarks: []WatermarkConfig{
+		{Name: "newest", Selector: ".[].updatedAt"},
+		{Name: "oldest", Selector: ".[].updatedAt", Aggregate: "min"},
+		{Name: "lastId", Selector: ".[].id", Aggregate: "last"},
+	}}
+	selectors, err := compileWatermarks(cfg)
+	require.Nil(t, err)
+
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	require.Nil(t, store.Save(map[string]any{"newest": "2024-06-01", "oldest": "2024-01-01", "lastId": float64(9)}))
+
+	// e.g. a late-arriving page, or an API that ignores the since filter
+	tracker, err := newWatermarkTracker(cfg, selectors, store)
+	require.Nil(t, err)
+	require.Nil(t, tracker.observe([]any{
+		map[string]any{"id": float64(2), "updatedAt": "2024-05-02"},
+	}))
+	require.Nil(t, tracker.commit())
+
+	state, err := store.Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"newest": "2024-06-01",
+		"oldest": "2024-01-01",
+		"lastId": float64(2),
+	}, state, "max and min keep the saved value, last is overwritten")
+}
+
+func TestIncrementalRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+	configContent := fmt.Sprintf(`
+rootContext: []
+incremental:
+  stateFile: %s
+  watermarks:
+    - name: since
+      selector: .[].updatedAt
+      default: "1970-01-01T00:00:00Z"
+steps:
+  - type: request
+    name: "Fetch changes"
+    request:
+      url: https://api.example.com/changes?since={{ .since }}
+      method: GET
+`, statePath)
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	mockTransport := crawler_testing.NewMockRoundTripper(map[string]string{
+		"https://api.example.com/changes?since=1970-01-01T00:00:00Z": "testdata/crawler/incremental/changes_initial.json",
+		"https://api.example.com/changes?since=2024-05-03T08:00:00Z": "testdata/crawler/incremental/changes_next.json",
+	})
+	recorder := &requestRecorder{}
+	mockTransport.InterceptFunc = recorder.intercept
+
+	run := func(vars map[string]any) error {
+		craw, _, err := NewApiCrawler(configPath)
+		require.Nil(t, err)
+		craw.SetClient(&http.Client{Transport: mockTransport})
+		return craw.Run(context.TODO(), vars)
+	}
+
+	// A failed run does not advance the watermark
+	recorder.reset("https://api.example.com/changes?since=1970-01-01T00:00:00Z")
+	require.NotNil(t, run(nil))
+	_, err := os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+
+	// The first successful run starts from the default
+	recorder.reset()
+	require.Nil(t, run(nil))
+
+	// The next run continues from the highest updatedAt
+	require.Nil(t, run(nil))
+
+	// Explicit vars win over saved watermarks
+	require.Nil(t, run(map[string]any{"since": "1970-01-01T00:00:00Z"}))
+
+	assert.Equal(t, []string{
+		"https://api.example.com/changes?since=1970-01-01T00:00:00Z",
+		"https://api.example.com/changes?since=2024-05-03T08:00:00Z",
+		"https://api.example.com/changes?since=1970-01-01T00:00:00Z",
+	}, recorder.urls)
+
+	state, err := NewFileStateStore(statePath).Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{"since": "2024-05-03T08:00:00Z"}, state)
+}
+
+func TestIncrementalStream(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+incremental:
+  watermarks:
+    - name: lastId
+      selector: .id
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 4},
+			map[string]interface{}{"id": 9},
+			map[string]interface{}{"id": 2},
+		},
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	craw.SetStateStore(store)
+
+	stream := craw.GetDataStream()
+	done := make(chan struct{})
+	count := 0
+	go func() {
+		for range stream {
+			count++
+		}
+		close(done)
+	}()
+
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+	close(stream)
+	<-done
+
+	assert.Equal(t, 3, count)
+	state, err := store.Load()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{"lastId": float64(9)}, state)
+}
+
+func TestValidateIncremental(t *testing.T) {
+	errs := validateIncremental(IncrementalConfig{Watermarks: []WatermarkConfig{
+		{Name: "since", Selector: ".[].updatedAt"},
+		{Name: "since", Selector: ".[].id", Aggregate: "sum"},
+		{Selector: ".x"},
+	}}, "incremental")
+	require.Len(t, errs, 3)
+	assert.Equal(t, "incremental.watermarks[1].name", errs[0].Location)
+	assert.Equal(t, "incremental.watermarks[1].aggregate", errs[1].Location)
+	assert.Equal(t, "incremental.watermarks[2].name", errs[2].Location)
+
+	_, validationErrors, err := ValidateAndCompile(Config{
+		RootContext: []interface{}{},
+		Incremental: &IncrementalConfig{Watermarks: []WatermarkConfig{{Name: "since", Selector: ".[] |"}}},
+		Steps: []Step{{
+			Type:    "request",
+			Request: &RequestConfig{URL: "https://api.example.com", Method: "GET"},
+		}},
+	})
+	require.Nil(t, err)
+	require.Len(t, validationErrors, 1)
+	assert.Equal(t, "compilation", validationErrors[0].Location)
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Supported values for Pagination.OnLoop
+const (
+	OnLoopError  = "error"  // abort the step with a PaginationLoopError (default)
+	OnLoopStop   = "stop"   // log a warning and end the pagination
+	OnLoopIgnore = "ignore" // do not detect loops, e.g. for queue-like APIs returning new data for the same request
+)
+
+// PaginationLoopError is returned when the next page would repeat the request
+// of an earlier page, e.g. because the API keeps returning the same cursor
+type PaginationLoopError struct {
+	StepPath     string
+	Page         int            // page that would be requested
+	RepeatedPage int            // earlier page with the same request
+	Params       map[string]any // pagination params of the repeated request
+	NextPageUrl  string         // next page URL of the repeated request
+}
+
+func (e *PaginationLoopError) Error() string {
+	msg := fmt.Sprintf("%s: pagination loop detected: page %d would repeat the request of page %d", e.StepPath, e.Page, e.RepeatedPage)
+	if e.NextPageUrl != "" {
+		msg += fmt.Sprintf(" (nextPageUrl %s)", e.NextPageUrl)
+	} else if len(e.Params) > 0 {
+		params, _ := json.Marshal(e.Params)
+		msg += fmt.Sprintf(" (params %s)", params)
+	}
+	return msg
+}
+
+// isValidOnLoop reports whether mode is a supported onLoop value
+func isValidOnLoop(mode string) bool {
+	switch mode {
+	case "", OnLoopError, OnLoopStop, OnLoopIgnore:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint identifies the request of the current page
+func (p *Paginator) requestFingerprint() string {
+	state := p.State()
+	fingerprint, _ := json.Marshal(struct {
+		Ctx         map[string]any
+		NextPageUrl string
+		Window      *PaginationWindowState
+	}{state.Ctx, state.NextPageUrl, state.Window})
+	return string(fingerprint)
+}
+
+// rememberRequest records the request of the current page for loop detection
+func (p *Paginator) rememberRequest() {
+	if p.config.Pagination.OnLoop == OnLoopIgnore {
+		return
+	}
+	if p.seen == nil {
+		p.seen = make(map[string]int)
+	}
+	fingerprint := p.requestFingerprint()
+	if _, exists := p.seen[fingerprint]; !exists {
+		p.seen[fingerprint] = p.pageNum
+	}
+}
+
+// checkLoop returns a PaginationLoopError if the current page repeats the
+// request of an earlier one
+func (p *Paginator) checkLoop() error {
+	if p.config.Pagination.OnLoop == OnLoopIgnore {
+		return nil
+	}
+	repeated, ok := p.seen[p.requestFingerprint()]
+	if !ok {
+		return nil
+	}
+	state := p.State()
+	return &PaginationLoopError{
+		Page:         p.pageNum,
+		RepeatedPage: repeated,
+		Params:       state.Ctx,
+		NextPageUrl:  state.NextPageUrl,
+	}
+}
+
+// paginationLimit returns why no page after pagesRequested pages may be
+// requested, or "" if the maxPages and maxDuration limits allow it
+func (r *requestRun) paginationLimit(pagesRequested int) string {
+	pagination := r.exec.step.Request.Pagination
+	if pagination.MaxPages > 0 && pagesRequested >= pagination.MaxPages {
+		return fmt.Sprintf("maxPages %d reached", pagination.MaxPages)
+	}
+	if !r.deadline.IsZero() && !time.Now().Before(r.deadline) {
+		return fmt.Sprintf("maxDuration %s reached", pagination.MaxDuration)
+	}
+	return ""
+}
@@ -0,0 +1,239 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPagesCrawler returns a crawler running a single request step with the given
+// pagination, answered by respond; requested URLs are appended to urls
+func newPagesCrawler(t *testing.T, pagination string, respond func(req *http.Request) string) (*ApiCrawler, *[]string) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+` + pagination + `
+    resultTransformer: .items
+    mergeOn: . + $res
+`
+	var mu sync.Mutex
+	urls := []string{}
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		mu.Lock()
+		urls = append(urls, req.URL.String())
+		mu.Unlock()
+		return respond(req)
+	}))
+	return craw, &urls
+}
+
+func TestPaginationLoopRepeatedNextUrl(t *testing.T) {
+	craw, urls := newPagesCrawler(t, `
+        nextPageUrlSelector: "body:.next"
+`, func(req *http.Request) string {
+		// Page b links back to page a
+		if req.URL.Query().Get("page") == "b" {
+			return `{"items": [2], "next": "https://api.example.com/items?page=a"}`
+		}
+		return `{"items": [1], "next": "https://api.example.com/items?page=b"}`
+	})
+
+	err := craw.Run(context.TODO(), nil)
+	var loopErr *PaginationLoopError
+	require.True(t, errors.As(err, &loopErr), "got %v", err)
+	assert.Equal(t, "steps[0]", loopErr.StepPath)
+	assert.Equal(t, 3, loopErr.Page)
+	assert.Equal(t, 1, loopErr.RepeatedPage)
+	assert.Equal(t, "https://api.example.com/items?page=b", loopErr.NextPageUrl)
+	assert.Contains(t, err.Error(), "steps[0]: pagination loop detected: page 3 would repeat the request of page 1")
+	assert.Len(t, *urls, 3)
+}
+
+func TestPaginationLoopRepeatedCursorStop(t *testing.T) {
+	craw, urls := newPagesCrawler(t, `
+        params:
+          - name: cursor
+            location: query
+            type: dynamic
+            source: "body:.cursor"
+        stopOn:
+          - type: responseBody
+            expression: ".cursor == null"
+        onLoop: stop
+`, func(req *http.Request) string {
+		// The API gets stuck on cursor c2
+		if req.URL.Query().Get("cursor") == "" {
+			return `{"items": [1], "cursor": "c2"}`
+		}
+		return `{"items": [2], "cursor": "c2"}`
+	})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Equal(t, []string{
+		"https://api.example.com/items",
+		"https://api.example.com/items?cursor=c2",
+	}, *urls)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, craw.GetData(), "pages before the loop are kept")
+}
+
+func TestPaginationLoopIgnore(t *testing.T) {
+	remaining := 3
+	craw, urls := newPagesCrawler(t, `
+        params:
+          - name: batch
+            location: query
+            type: int
+            default: "100"
+        stopOn:
+          - type: responseBody
+            expression: ".items | length == 0"
+        onLoop: ignore
+`, func(req *http.Request) string {
+		// A queue returning new items for the same request until it is empty
+		if remaining == 0 {
+			return `{"items": []}`
+		}
+		remaining--
+		return `{"items": [1]}`
+	})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Len(t, *urls, 4)
+	assert.Equal(t, []interface{}{float64(1), float64(1), float64(1)}, craw.GetData())
+}
+
+func TestPaginationMaxPages(t *testing.T) {
+	craw, urls := newPagesCrawler(t, `
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "0"
+            increment: "+ 1"
+        stopOn:
+          - type: responseBody
+            expression: "false"
+        maxPages: 3
+`, func(req *http.Request) string {
+		return `{"items": [` + req.URL.Query().Get("page") + `]}`
+	})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Len(t, *urls, 3)
+	assert.Equal(t, []interface{}{float64(0), float64(1), float64(2)}, craw.GetData())
+}
+
+func TestPaginationMaxDuration(t *testing.T) {
+	craw, urls := newPagesCrawler(t, `
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "0"
+            increment: "+ 1"
+        stopOn:
+          - type: responseBody
+            expression: "false"
+        maxDuration: 50ms
+`, func(req *http.Request) string {
+		time.Sleep(20 * time.Millisecond)
+		return `{"items": [1]}`
+	})
+
+	start := time.Now()
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.GreaterOrEqual(t, len(*urls), 2)
+	assert.LessOrEqual(t, len(*urls), 4)
+}
+
+func TestPaginationMaxPagesWithTotal(t *testing.T) {
+	craw, urls := newPagesCrawler(t, `
+        params:
+          - name: page
+            location: query
+            type: int
+            default: "0"
+            increment: "+ 1"
+        total:
+          selector: .pages
+        parallelism:
+          maxConcurrency: 2
+        maxPages: 4
+`, func(req *http.Request) string {
+		return `{"pages": 50, "items": [` + req.URL.Query().Get("page") + `]}`
+	})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	assert.Len(t, *urls, 4)
+	assert.Equal(t, []interface{}{float64(0), float64(1), float64(2), float64(3)}, craw.GetData())
+}
+
+func TestValidatePaginationLimits(t *testing.T) {
+	errs := validatePagination(Pagination{
+		NextPageUrlSelector: "body:.next",
+		MaxPages:            -1,
+		MaxDuration:         "ten minutes",
+		OnLoop:              "warn",
+	}, "pagination")
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"pagination.maxPages",
+		"pagination.maxDuration",
+		"pagination.onLoop",
+	}, locations)
+
+	assert.Empty(t, validatePagination(Pagination{
+		NextPageUrlSelector: "body:.next",
+		MaxPages:            10,
+		MaxDuration:         "1h30m",
+		OnLoop:              OnLoopStop,
+	}, "pagination"))
+}
+
+func TestValidatePaginationLimitsInConfig(t *testing.T) {
+	errs := ValidateConfig(Config{
+		RootContext: []interface{}{},
+		Steps: []Step{{
+			Type: "request",
+			Request: &RequestConfig{
+				URL:    "https://api.example.com",
+				Method: "GET",
+				Pagination: Pagination{
+					NextPageUrlSelector: "body:.next",
+					MaxPages:            -5,
+					MaxDuration:         "abc",
+					OnLoop:              "weird",
+				},
+			},
+		}},
+	})
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"steps[0].request.pagination.maxPages",
+		"steps[0].request.pagination.maxDuration",
+		"steps[0].request.pagination.onLoop",
+	}, locations)
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PaginationPreset is the pagination of a common API convention, selected
+// with Pagination.Preset. Fields set in the config take precedence.
+type PaginationPreset struct {
+	NextPageUrlSelector string
+	Params              []Param
+	StopOn              []StopCondition
+}
+
+var (
+	paginationPresetsMutex sync.RWMutex
+	paginationPresets      = map[string]PaginationPreset{
+		// OData v4: the next page link is in @odata.nextLink
+		"odata": {NextPageUrlSelector: `body:."@odata.nextLink"`},
+		// JSON:API: the next page link is in links.next
+		"jsonapi": {NextPageUrlSelector: "body:.links.next"},
+		// HAL: the next page link is in _links.next.href
+		"hal": {NextPageUrlSelector: "body:._links.next.href"},
+		// Spring Data: zero-based page and size params; the page metadata is
+		// either at the top level (Page) or in .page (PagedModel)
+		"spring": {
+			Params: []Param{
+				{Name: "page", Location: "query", Type: "int", Default: "0", Increment: "+ 1"},
+				{Name: "size", Location: "query", Type: "int", Default: "20"},
+			},
+			StopOn: []StopCondition{
+				{Type: "responseBody", Expression: "(.page // .) | .number + 1 >= .totalPages"},
+			},
+		},
+		// Elasticsearch: search_after is set in the request body from the sort
+		// values of the last hit, until a page has no hits
+		"elasticsearch": {
+			Params: []Param{
+				{Name: "search_after", Location: "body", Type: "dynamic", Source: "body:.hits.hits[-1].sort"},
+			},
+			StopOn: []StopCondition{
+				{Type: "responseBody", Expression: ".hits.hits | length == 0"},
+			},
+		},
+	}
+)
+
+// RegisterPaginationPreset makes a preset available to Pagination.Preset
+// under name, replacing any preset registered with the same name
+func RegisterPaginationPreset(name string, preset PaginationPreset) {
+	paginationPresetsMutex.Lock()
+	defer paginationPresetsMutex.Unlock()
+	paginationPresets[name] = preset
+}
+
+// PaginationPresetNames returns the names of the registered presets
+func PaginationPresetNames() []string {
+	paginationPresetsMutex.RLock()
+	defer paginationPresetsMutex.RUnlock()
+	names := make([]string, 0, len(paginationPresets))
+	for name := range paginationPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPreset returns the pagination with the fields of its preset filled in.
+// StopOn and NextPageUrlSelector of the config replace those of the preset;
+// params replace the preset params of the same name.
+func (p Pagination) withPreset() (Pagination, error) {
+	if p.Preset == "" {
+		return p, nil
+	}
+	paginationPresetsMutex.RLock()
+	preset, ok := paginationPresets[p.Preset]
+	paginationPresetsMutex.RUnlock()
+	if !ok {
+		return p, fmt.Errorf("unknown pagination preset '%s'", p.Preset)
+	}
+
+	if p.NextPageUrlSelector == "" {
+		p.NextPageUrlSelector = preset.NextPageUrlSelector
+	}
+	if len(p.StopOn) == 0 {
+		p.StopOn = preset.StopOn
+	}
+
+	overrides := make(map[string]Param, len(p.Params))
+	for _, param := range p.Params {
+		overrides[param.Name] = param
+	}
+	params := make([]Param, 0, len(preset.Params)+len(p.Params))
+	for _, param := range preset.Params {
+		if override, ok := overrides[param.Name]; ok {
+			param = override
+			delete(overrides, param.Name)
+		}
+		params = append(params, param)
+	}
+	for _, param := range p.Params {
+		if _, ok := overrides[param.Name]; ok {
+			params = append(params, param)
+		}
+	}
+	p.Params = params
+	return p, nil
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinPaginationPresets(t *testing.T) {
+	for _, name := range []string{"odata", "jsonapi", "hal", "spring", "elasticsearch"} {
+		assert.Empty(t, validatePagination(Pagination{Preset: name}, "pagination"), name)
+		_, err := NewPaginator(ConfigP{Pagination: Pagination{Preset: name}})
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestPaginationPresetNextLink(t *testing.T) {
+	p, err := NewPaginator(ConfigP{Pagination: Pagination{Preset: "odata"}})
+	require.NoError(t, err)
+
+	parts, done, err := p.NextWithBody(&http.Response{}, map[string]any{
+		"value":           []any{},
+		"@odata.nextLink": "https://api.example.com/People?$skiptoken=8",
+	})
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, "https://api.example.com/People?$skiptoken=8", parts.NextPageUrl)
+
+	_, done, err = p.NextWithBody(&http.Response{}, map[string]any{"value": []any{}})
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestPaginationPresetOverrides(t *testing.T) {
+	resolved, err := Pagination{
+		Preset: "spring",
+		Params: []Param{
+			{Name: "size", Location: "query", Type: "int", Default: "100"},
+			{Name: "sort", Location: "query", Type: "string", Default: "id,asc"},
+		},
+		MaxPages: 10,
+	}.withPreset()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Param{
+		{Name: "page", Location: "query", Type: "int", Default: "0", Increment: "+ 1"},
+		{Name: "size", Location: "query", Type: "int", Default: "100"},
+		{Name: "sort", Location: "query", Type: "string", Default: "id,asc"},
+	}, resolved.Params, "params replace preset params of the same name")
+	assert.Len(t, resolved.StopOn, 1)
+	assert.Equal(t, 10, resolved.MaxPages)
+
+	resolved, err = Pagination{
+		Preset: "spring",
+		StopOn: []StopCondition{{Type: "pageNum", Value: 3}},
+	}.withPreset()
+	require.NoError(t, err)
+	assert.Equal(t, []StopCondition{{Type: "pageNum", Value: 3}}, resolved.StopOn)
+}
+
+func TestValidateUnknownPaginationPreset(t *testing.T) {
+	errs := validatePagination(Pagination{Preset: "graphql"}, "pagination")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "pagination.preset", errs[0].Location)
+	assert.Contains(t, errs[0].Message, "spring")
+
+	_, err := NewPaginator(ConfigP{Pagination: Pagination{Preset: "graphql"}})
+	assert.ErrorContains(t, err, "unknown pagination preset 'graphql'")
+}
+
+func TestRegisterPaginationPreset(t *testing.T) {
+	RegisterPaginationPreset("acme", PaginationPreset{
+		Params: []Param{
+			{Name: "cursor", Location: "query", Type: "dynamic", Source: "header:X-Next-Cursor"},
+		},
+		StopOn: []StopCondition{{Type: "expression", Expression: `!("X-Next-Cursor" in headers)`}},
+	})
+	t.Cleanup(func() {
+		paginationPresetsMutex.Lock()
+		delete(paginationPresets, "acme")
+		paginationPresetsMutex.Unlock()
+	})
+	assert.Contains(t, PaginationPresetNames(), "acme")
+
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/items
+      method: GET
+      pagination:
+        preset: acme
+`
+	var urls []string
+	craw := newTestCrawler(t, configContent, clientFunc(func(req *http.Request) (*http.Response, error) {
+		urls = append(urls, req.URL.String())
+		cursor := req.URL.Query().Get("cursor")
+		resp := jsonResponse(req, fmt.Sprintf(`[{"page": "%s"}]`, cursor))
+		if cursor == "" {
+			resp.Header.Set("X-Next-Cursor", "c2")
+		}
+		return resp, nil
+	}))
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.Equal(t, []string{
+		"https://api.example.com/items",
+		"https://api.example.com/items?cursor=c2",
+	}, urls)
+	assert.Equal(t, []any{
+		map[string]any{"page": ""},
+		map[string]any{"page": "c2"},
+	}, craw.GetData())
+}
@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// stopExprEnv is the environment of expression stop conditions
+type stopExprEnv struct {
+	PageNum     int               `expr:"pageNum"`     // number of the page that would be requested next
+	Params      map[string]any    `expr:"params"`      // param values of the next request (datetimes formatted)
+	Body        any               `expr:"body"`        // decoded response body
+	Headers     map[string]string `expr:"headers"`     // first value of each response header, by canonical name
+	NextPageUrl string            `expr:"nextPageUrl"` // next page url extracted from the response
+}
+
+// compiledStop is a stop condition with its expressions compiled and its
+// param resolved, so a broken condition fails when the config is loaded
+type compiledStop struct {
+	kind    string // lower-cased condition type, "all" or "any"
+	jq      *CompiledJQ
+	program *vm.Program
+	param   Param
+	compare string
+	value   any
+	pageNum int
+	group   []*compiledStop
+}
+
+// compileStopConditions compiles the stopOn conditions of a pagination
+func compileStopConditions(conds []StopCondition, params []Param) ([]*compiledStop, error) {
+	compiled := make([]*compiledStop, 0, len(conds))
+	for i, cond := range conds {
+		c, err := compileStopCondition(cond, params, fmt.Sprintf("stopOn[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compileStopCondition(cond StopCondition, params []Param, location string) (*compiledStop, error) {
+	if len(cond.All) > 0 || len(cond.Any) > 0 {
+		if cond.Type != "" || (len(cond.All) > 0 && len(cond.Any) > 0) {
+			return nil, fmt.Errorf("%s: a condition is either a type, an all group or an any group", location)
+		}
+		c := &compiledStop{kind: "all"}
+		group, name := cond.All, "all"
+		if len(cond.Any) > 0 {
+			c.kind, group, name = "any", cond.Any, "any"
+		}
+		for i, nested := range group {
+			n, err := compileStopCondition(nested, params, fmt.Sprintf("%s.%s[%d]", location, name, i))
+			if err != nil {
+				return nil, err
+			}
+			c.group = append(c.group, n)
+		}
+		return c, nil
+	}
+
+	c := &compiledStop{kind: strings.ToLower(cond.Type)}
+	var err error
+	switch c.kind {
+	case "pagenum":
+		c.pageNum, err = stopPageNum(cond.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+
+	case "responsebody":
+		if cond.Expression == "" {
+			return nil, fmt.Errorf("%s: responseBody requires an expression", location)
+		}
+		c.jq, err = compileJQ(cond.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+
+	case "expression":
+		if cond.Expression == "" {
+			return nil, fmt.Errorf("%s: expression requires an expression", location)
+		}
+		c.program, err = expr.Compile(cond.Expression, expr.Env(stopExprEnv{}), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid expression '%s': %w", location, cond.Expression, err)
+		}
+
+	case "requestparam":
+		paramLoc, paramName, err := parseParamPath(cond.Param)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+		found := false
+		for _, pdef := range params {
+			if pdef.Location == paramLoc && pdef.Name == paramName {
+				c.param, found = pdef, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: param definition not found for %s", location, cond.Param)
+		}
+		c.compare, c.value = strings.ToLower(cond.Compare), cond.Value
+
+	default:
+		return nil, fmt.Errorf("%s: unsupported stop condition type '%s'", location, cond.Type)
+	}
+	return c, nil
+}
+
+// stopPageNum converts the value of a pageNum condition, which is decoded as
+// float64 from JSON configs
+func stopPageNum(value any) (int, error) {
+	f, err := toFloat64(value)
+	if err != nil || f != float64(int(f)) {
+		return 0, fmt.Errorf("pageNum value must be a whole number, got %v", value)
+	}
+	return int(f), nil
+}
+
+// matches evaluates the condition against the response of the current page
+func (c *compiledStop) matches(p *Paginator, body any, env *stopExprEnv) (bool, error) {
+	switch c.kind {
+	case "all", "any":
+		for _, nested := range c.group {
+			ok, err := nested.matches(p, body, env)
+			if err != nil {
+				return false, err
+			}
+			if ok == (c.kind == "any") {
+				return ok, nil
+			}
+		}
+		return c.kind == "all", nil
+
+	case "pagenum":
+		return p.pageNum >= c.pageNum, nil
+
+	case "responsebody":
+		res, err := c.jq.Run(body)
+		if err != nil {
+			return false, err
+		}
+		b, ok := res.(bool)
+		return ok && b, nil
+
+	case "expression":
+		res, err := expr.Run(c.program, env)
+		if err != nil {
+			return false, fmt.Errorf("stop expression: %w", err)
+		}
+		b, ok := res.(bool)
+		if !ok {
+			return false, fmt.Errorf("stop expression must return a bool, got %T", res)
+		}
+		return b, nil
+
+	case "requestparam":
+		val := p.ctx[c.param.Name]
+		if val == nil {
+			return false, nil
+		}
+		return compareValues(c.param, val, c.value, c.compare)
+	}
+	return false, nil
+}
+
+// stopEnv builds the environment of expression stop conditions
+func (p *Paginator) stopEnv(body any, headers map[string][]string) *stopExprEnv {
+	values := make(map[string]string, len(headers))
+	for name, vals := range headers {
+		if len(vals) > 0 {
+			values[http.CanonicalHeaderKey(name)] = vals[0]
+		}
+	}
+	return &stopExprEnv{
+		PageNum:     p.pageNum,
+		Params:      p.State().Ctx,
+		Body:        body,
+		Headers:     values,
+		NextPageUrl: p.nextPageUrl,
+	}
+}
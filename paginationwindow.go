@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"fmt"
+	"time"
+)
+
+// PaginationWindow paginates over consecutive [start, end) time windows, for
+// APIs that only accept date ranges of a limited length
+type PaginationWindow struct {
+	Start   string      `yaml:"start" json:"start"`                         // start of the first window: timestamp in Format or "now" expression (e.g. "now-30d")
+	End     string      `yaml:"end,omitempty" json:"end,omitempty"`         // end of the last window, same syntax (default "now")
+	Format  string      `yaml:"format,omitempty" json:"format,omitempty"`   // Go time format of Start and End (default RFC3339)
+	Size    string      `yaml:"size" json:"size"`                           // window length, e.g. "7d", "12h"
+	Overlap string      `yaml:"overlap,omitempty" json:"overlap,omitempty"` // how far each window reaches back into the previous one, e.g. "1h"
+	From    WindowBound `yaml:"from" json:"from"`                           // param receiving the window start
+	To      WindowBound `yaml:"to" json:"to"`                               // param receiving the window end
+}
+
+// WindowBound binds a window boundary to a request param
+type WindowBound struct {
+	Name     string `yaml:"name" json:"name"`
+	Location string `yaml:"location" json:"location"`                     // query, body or header
+	Format   string `yaml:"format,omitempty" json:"format,omitempty"`     // Go time format (default RFC3339)
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"` // IANA time zone, e.g. "Europe/Rome" (default UTC)
+}
+
+// PaginationWindowState is the serializable position of a window pagination
+type PaginationWindowState struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// windowCursor tracks the current window of a window pagination
+type windowCursor struct {
+	config   *PaginationWindow
+	start    time.Time // current window is [start, end)
+	end      time.Time
+	limit    time.Time // end of the last window
+	finished bool      // the last window has been requested
+	fromLoc  *time.Location
+	toLoc    *time.Location
+}
+
+// newWindowCursor resolves the bounds of a window pagination and positions it
+// on the first window
+func newWindowCursor(cfg *PaginationWindow) (*windowCursor, error) {
+	format := cfg.Format
+	if format == "" {
+		format = time.RFC3339
+	}
+	start, err := toTime(cfg.Start, format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window start: %w", err)
+	}
+	end := cfg.End
+	if end == "" {
+		end = "now"
+	}
+	limit, err := toTime(end, format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window end: %w", err)
+	}
+	if _, err := windowDuration(start, cfg.Size); err != nil {
+		return nil, fmt.Errorf("invalid window size: %w", err)
+	}
+
+	w := &windowCursor{config: cfg, start: start, limit: limit, finished: !start.Before(limit)}
+	if w.fromLoc, err = loadWindowLocation(cfg.From.Timezone); err != nil {
+		return nil, err
+	}
+	if w.toLoc, err = loadWindowLocation(cfg.To.Timezone); err != nil {
+		return nil, err
+	}
+	w.end, err = w.windowEnd(start)
+	return w, err
+}
+
+// windowDuration returns the length of a smart duration (e.g. "7d", "1M")
+// applied at t, which must be positive
+func windowDuration(t time.Time, expr string) (time.Duration, error) {
+	shifted, err := addSmartDuration(t, expr)
+	if err != nil {
+		return 0, err
+	}
+	d := shifted.Sub(t)
+	if d <= 0 {
+		return 0, fmt.Errorf("duration '%s' must be positive", expr)
+	}
+	return d, nil
+}
+
+// loadWindowLocation loads an IANA time zone, defaulting to UTC
+func loadWindowLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window timezone '%s': %w", name, err)
+	}
+	return loc, nil
+}
+
+// windowEnd returns the end of the window starting at start, capped at the limit
+func (w *windowCursor) windowEnd(start time.Time) (time.Time, error) {
+	end, err := addSmartDuration(start, w.config.Size)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end.After(w.limit) {
+		end = w.limit
+	}
+	return end, nil
+}
+
+// next moves to the following window, or marks the pagination finished once
+// the current window reached the end bound
+func (w *windowCursor) next() error {
+	if !w.end.Before(w.limit) {
+		w.finished = true
+		return nil
+	}
+	start := w.end
+	if w.config.Overlap != "" {
+		overlap, err := windowDuration(w.end, w.config.Overlap)
+		if err != nil {
+			return fmt.Errorf("invalid window overlap: %w", err)
+		}
+		start = w.end.Add(-overlap)
+	}
+	if !start.After(w.start) {
+		return fmt.Errorf("window overlap '%s' must be shorter than the window size '%s'", w.config.Overlap, w.config.Size)
+	}
+	end, err := w.windowEnd(start)
+	if err != nil {
+		return err
+	}
+	w.start, w.end = start, end
+	return nil
+}
+
+// addParams adds the bounds of the current window to the request params
+func (w *windowCursor) addParams(q map[string]string, h map[string]string, b map[string]interface{}) {
+	for _, bound := range []struct {
+		param WindowBound
+		value time.Time
+		loc   *time.Location
+	}{
+		{w.config.From, w.start, w.fromLoc},
+		{w.config.To, w.end, w.toLoc},
+	} {
+		format := bound.param.Format
+		if format == "" {
+			format = time.RFC3339
+		}
+		str := bound.value.In(bound.loc).Format(format)
+		switch bound.param.Location {
+		case "query":
+			q[bound.param.Name] = str
+		case "header":
+			h[bound.param.Name] = str
+		case "body":
+			b[bound.param.Name] = str
+		}
+	}
+}
+
+// state returns the serializable position of the cursor
+func (w *windowCursor) state() *PaginationWindowState {
+	return &PaginationWindowState{Start: w.start, End: w.end}
+}
+
+// restore moves the cursor to a saved position
+func (w *windowCursor) restore(state *PaginationWindowState) {
+	w.start, w.end = state.Start, state.End
+	w.finished = !w.start.Before(w.limit)
+}
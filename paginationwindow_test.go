@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeWindowLifecycle(t *testing.T) {
+	runPaginatorTest(t, "testdata/paginator/test13_time_window.yaml", 4)
+}
+
+func TestTimeWindowStateRestore(t *testing.T) {
+	cfg := ConfigP{Pagination: Pagination{Window: &PaginationWindow{
+		Start:  "2025-01-01",
+		End:    "2025-01-10",
+		Format: "2006-01-02",
+		Size:   "3d",
+		From:   WindowBound{Name: "from", Location: "body", Format: "2006-01-02"},
+		To:     WindowBound{Name: "to", Location: "body", Format: "2006-01-02"},
+	}}}
+	p, err := NewPaginator(cfg)
+	require.NoError(t, err)
+
+	parts, err := p.RequestParts()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"from": "2025-01-01", "to": "2025-01-04"}, parts.BodyParams)
+
+	parts, stop, err := p.NextWithBody(&http.Response{}, []any{})
+	require.NoError(t, err)
+	require.False(t, stop)
+	assert.Equal(t, map[string]interface{}{"from": "2025-01-04", "to": "2025-01-07"}, parts.BodyParams)
+
+	// The window survives a JSON round trip of the state
+	raw, err := json.Marshal(p.State())
+	require.NoError(t, err)
+	var state PaginatorState
+	require.NoError(t, json.Unmarshal(raw, &state))
+
+	resumed, err := NewPaginator(cfg)
+	require.NoError(t, err)
+	resumed.Restore(state)
+	parts, err = resumed.RequestParts()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"from": "2025-01-04", "to": "2025-01-07"}, parts.BodyParams)
+
+	parts, stop, err = resumed.NextWithBody(&http.Response{}, []any{})
+	require.NoError(t, err)
+	require.False(t, stop)
+	assert.Equal(t, map[string]interface{}{"from": "2025-01-07", "to": "2025-01-10"}, parts.BodyParams)
+
+	_, stop, err = resumed.NextWithBody(&http.Response{}, []any{})
+	require.NoError(t, err)
+	assert.True(t, stop)
+}
+
+func TestTimeWindowEmptyRange(t *testing.T) {
+	_, err := NewPaginator(ConfigP{Pagination: Pagination{Window: &PaginationWindow{
+		Start: "2025-01-10T00:00:00Z",
+		End:   "2025-01-01T00:00:00Z",
+		Size:  "1d",
+	}}})
+	assert.ErrorContains(t, err, "window start must be before its end")
+}
+
+func TestTimeWindowRequests(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/measurements
+      method: GET
+      pagination:
+        window:
+          start: "2025-03-01T00:00:00Z"
+          end: "2025-03-15T00:00:00Z"
+          size: 7d
+          from:
+            name: from
+            location: query
+            format: "2006-01-02"
+          to:
+            name: to
+            location: query
+            format: "2006-01-02"
+    mergeOn: . + $res
+`
+	var queries []string
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		queries = append(queries, req.URL.RawQuery)
+		body, _ := json.Marshal([]string{req.URL.Query().Get("from")})
+		return string(body)
+	}))
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+	require.Len(t, queries, 2)
+	assert.Contains(t, queries[0], "from=2025-03-01")
+	assert.Contains(t, queries[0], "to=2025-03-08")
+	assert.Contains(t, queries[1], "from=2025-03-08")
+	assert.Contains(t, queries[1], "to=2025-03-15")
+	assert.Equal(t, []interface{}{"2025-03-01", "2025-03-08"}, craw.GetData())
+}
+
+func TestValidatePaginationWindow(t *testing.T) {
+	errs := validatePagination(Pagination{Window: &PaginationWindow{
+		Start:   "yesterday",
+		Size:    "1d",
+		Overlap: "2d",
+		From:    WindowBound{Name: "from", Location: "path"},
+		To:      WindowBound{Location: "query", Timezone: "Mars/Olympus"},
+	}}, "pagination")
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"pagination.window.start",
+		"pagination.window.overlap",
+		"pagination.window.from.location",
+		"pagination.window.to.name",
+		"pagination.window.to.timezone",
+	}, locations)
+
+	assert.Empty(t, validatePagination(Pagination{Window: &PaginationWindow{
+		Start: "now-30d",
+		Size:  "7d",
+		From:  WindowBound{Name: "from", Location: "query"},
+		To:    WindowBound{Name: "to", Location: "query", Timezone: "Europe/Rome"},
+	}}, "pagination"))
+}
+
+func TestValidateWindowOnlyPagination(t *testing.T) {
+	errs := ValidateConfig(Config{
+		RootContext: []interface{}{},
+		Steps: []Step{{
+			Type: "request",
+			Request: &RequestConfig{
+				URL:    "https://api.example.com",
+				Method: "GET",
+				Pagination: Pagination{Window: &PaginationWindow{
+					Start: "now-30d",
+					Size:  "7d",
+					From:  WindowBound{Name: "from", Location: "bogus"},
+					To:    WindowBound{Location: "query"},
+				}},
+			},
+		}},
+	})
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"steps[0].request.pagination.window.from.location",
+		"steps[0].request.pagination.window.to.name",
+	}, locations)
+}
@@ -6,17 +6,20 @@ package silky
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/expr-lang/expr"
-	"github.com/itchyny/gojq"
+	"github.com/expr-lang/expr/vm"
 	"gopkg.in/yaml.v3"
 )
 
@@ -28,25 +31,72 @@ type Param struct {
 	Default   string `yaml:"default" json:"default"`
 	Increment string `yaml:"increment,omitempty" json:"increment,omitempty"`
 	Source    string `yaml:"source,omitempty" json:"source,omitempty"` // "body:selector" or "header:selector"
+	// Template renders the value sent for query and header params from the current
+	// param values (e.g. "items={{ .offset }}-{{ add .offset 99 }}")
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
 }
 
 type StopCondition struct {
-	Type       string `yaml:"type" json:"type"`             // "responseBody", "requestParam", "pageNum"
-	Expression string `yaml:"expression" json:"expression"` // used by jq
+	Type       string `yaml:"type,omitempty" json:"type,omitempty"`             // "responseBody", "requestParam", "pageNum", "expression"
+	Expression string `yaml:"expression,omitempty" json:"expression,omitempty"` // jq for responseBody, expr for expression
 
 	Param   string `yaml:"param,omitempty" json:"param,omitempty"`     // for requestParam
 	Compare string `yaml:"compare,omitempty" json:"compare,omitempty"` // "lt", "lte", "eq", "gt", "gte"
 	Value   any    `yaml:"value,omitempty" json:"value,omitempty"`     // value to compare against
+
+	// Groups replace the type: All matches when every nested condition
+	// matches, Any when at least one does
+	All []StopCondition `yaml:"all,omitempty" json:"all,omitempty"`
+	Any []StopCondition `yaml:"any,omitempty" json:"any,omitempty"`
 }
 
 type Pagination struct {
-	NextPageUrlSelector string          `yaml:"nextPageUrlSelector,omitempty" json:"nextPageUrlSelector,omitempty"` // jq selector to get nextPage url
+	// Preset fills in the params, stop conditions and next page url of a
+	// common API convention (see RegisterPaginationPreset)
+	Preset string `yaml:"preset,omitempty" json:"preset,omitempty"`
+
+	NextPageUrlSelector string          `yaml:"nextPageUrlSelector,omitempty" json:"nextPageUrlSelector,omitempty"` // next page url source: body:<jq>, header:<name> or link:<rel>
 	Params              []Param         `yaml:"params,omitempty" json:"params,omitempty"`
 	StopOn              []StopCondition `yaml:"stopOn,omitempty" json:"stopOn,omitempty"`
+
+	// Safety limits: pagination stops with a warning once either is reached
+	MaxPages    int    `yaml:"maxPages,omitempty" json:"maxPages,omitempty"`       // maximum pages per step execution (0 = unlimited)
+	MaxDuration string `yaml:"maxDuration,omitempty" json:"maxDuration,omitempty"` // no new page after this duration, e.g. "10m"
+	OnLoop      string `yaml:"onLoop,omitempty" json:"onLoop,omitempty"`           // error (default), stop or ignore
+
+	// Total reads the page count from the first response; the remaining pages
+	// are then fetched under Parallelism (sequentially if not set)
+	Total       *PaginationTotal   `yaml:"total,omitempty" json:"total,omitempty"`
+	Parallelism *ParallelismConfig `yaml:"parallelism,omitempty" json:"parallelism,omitempty"`
+
+	// Window paginates over consecutive time windows instead of params
+	Window *PaginationWindow `yaml:"window,omitempty" json:"window,omitempty"`
+}
+
+// isEmpty reports whether no pagination field is set
+func (p Pagination) isEmpty() bool {
+	return p.Preset == "" && p.NextPageUrlSelector == "" && len(p.Params) == 0 && len(p.StopOn) == 0 &&
+		p.MaxPages == 0 && p.MaxDuration == "" && p.OnLoop == "" &&
+		p.Total == nil && p.Parallelism == nil && p.Window == nil
+}
+
+// Supported values for PaginationTotal.Unit
+const (
+	PaginationTotalPages = "pages" // the selector returns the number of pages (default)
+	PaginationTotalItems = "items" // the selector returns the number of items, divided by PageSize
+)
+
+// PaginationTotal locates the total number of pages (or items) in the first
+// response of an offset or page-number paginated request
+type PaginationTotal struct {
+	Selector string `yaml:"selector" json:"selector"`                     // jq selector on the response body
+	Unit     string `yaml:"unit,omitempty" json:"unit,omitempty"`         // "pages" (default) or "items"
+	PageSize int    `yaml:"pageSize,omitempty" json:"pageSize,omitempty"` // items per page, required if unit is items
 }
 
 type ConfigP struct {
 	Pagination Pagination `yaml:"pagination"`
+	NumberMode string     `yaml:"numberMode,omitempty"` // how Next decodes response numbers, as Config.NumberMode
 }
 
 type PaginationContext map[string]interface{}
@@ -57,6 +107,9 @@ type Paginator struct {
 	stopped     bool
 	pageNum     int
 	nextPageUrl string
+	compiled    *CompiledPagination // pre-compiled expressions of the config
+	seen        map[string]int      // page number by request fingerprint, for loop detection
+	window      *windowCursor       // current time window (nil without window pagination)
 }
 
 type RequestParts struct {
@@ -66,12 +119,120 @@ type RequestParts struct {
 	NextPageUrl string                 `yaml:"nextPageUrl"`
 }
 
+// CompiledPagination holds the pre-compiled expressions of a pagination config
+type CompiledPagination struct {
+	NextPageUrl *CompiledJQ                  // body: source of nextPageUrlSelector
+	Sources     map[string]*CompiledJQ       // body: sources of dynamic params by param name
+	Increments  map[string]*vm.Program       // int and float increments by param name
+	Templates   map[string]*CompiledTemplate // param value templates by param name
+	Total       *CompiledJQ                  // total selector
+
+	stops []*compiledStop // stopOn conditions
+}
+
+// compilePagination compiles all expressions of a pagination config
+func compilePagination(cfg Pagination) (*CompiledPagination, error) {
+	cfg, err := cfg.withPreset()
+	if err != nil {
+		return nil, err
+	}
+	cp := &CompiledPagination{
+		Sources:    make(map[string]*CompiledJQ),
+		Increments: make(map[string]*vm.Program),
+		Templates:  make(map[string]*CompiledTemplate),
+	}
+
+	if sourceType, sourcePath, _ := strings.Cut(cfg.NextPageUrlSelector, ":"); sourceType == "body" {
+		cp.NextPageUrl, err = compileJQ(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("nextPageUrlSelector: %w", err)
+		}
+	}
+
+	for _, param := range cfg.Params {
+		switch param.Type {
+		case "dynamic":
+			if sourceType, sourcePath, _ := strings.Cut(param.Source, ":"); sourceType == "body" {
+				code, err := compileJQ(sourcePath)
+				if err != nil {
+					return nil, fmt.Errorf("source of param '%s': %w", param.Name, err)
+				}
+				cp.Sources[param.Name] = code
+			}
+		case "int", "float":
+			if param.Increment != "" {
+				prog, err := expr.Compile(fmt.Sprintf("x %s", param.Increment))
+				if err != nil {
+					return nil, fmt.Errorf("invalid increment for param '%s': %w", param.Name, err)
+				}
+				cp.Increments[param.Name] = prog
+			}
+		case "datetime":
+			if param.Increment != "" && !smartDurationPattern.MatchString(param.Increment) {
+				return nil, fmt.Errorf("invalid increment for param '%s': invalid duration: %s", param.Name, param.Increment)
+			}
+		}
+
+		if param.Template != "" {
+			tmpl, err := compileTemplate(param.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template for param '%s': %w", param.Name, err)
+			}
+			if tmpl != nil {
+				cp.Templates[param.Name] = tmpl
+			}
+		}
+	}
+
+	if cfg.Total != nil {
+		cp.Total, err = compileJQ(cfg.Total.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("total: %w", err)
+		}
+	}
+
+	cp.stops, err = compileStopConditions(cfg.StopOn, cfg.Params)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
 // NewPaginator creates a new paginator from YAML config
 func NewPaginator(cfg ConfigP) (*Paginator, error) {
+	return newCompiledPaginator(cfg, nil)
+}
+
+// newCompiledPaginator creates a paginator using the expressions compiled
+// with the step; they are compiled from cfg when compiled is nil
+func newCompiledPaginator(cfg ConfigP, compiled *CompiledPagination) (*Paginator, error) {
+	pagination, err := cfg.Pagination.withPreset()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Pagination = pagination
+
+	if compiled == nil {
+		if compiled, err = compilePagination(cfg.Pagination); err != nil {
+			return nil, err
+		}
+	}
 	p := &Paginator{
-		config:  cfg,
-		ctx:     make(PaginationContext),
-		stopped: len(cfg.Pagination.Params) == 0 && len(cfg.Pagination.NextPageUrlSelector) == 0,
+		config:   cfg,
+		ctx:      make(PaginationContext),
+		stopped:  len(cfg.Pagination.Params) == 0 && len(cfg.Pagination.NextPageUrlSelector) == 0 && cfg.Pagination.Window == nil,
+		compiled: compiled,
+	}
+
+	if cfg.Pagination.Window != nil {
+		window, err := newWindowCursor(cfg.Pagination.Window)
+		if err != nil {
+			return nil, err
+		}
+		if window.finished {
+			return nil, fmt.Errorf("window start must be before its end")
+		}
+		p.window = window
 	}
 
 	// initialize context
@@ -87,6 +248,63 @@ func NewPaginatorFromFile(yamlData []byte) (*Paginator, error) {
 	return NewPaginator(cfg)
 }
 
+// PaginatorState is a serializable snapshot of the paginator progress
+type PaginatorState struct {
+	Ctx         map[string]any `json:"ctx,omitempty"`
+	PageNum     int            `json:"pageNum"`
+	NextPageUrl string         `json:"nextPageUrl,omitempty"`
+	Stopped     bool           `json:"stopped,omitempty"`
+
+	Window *PaginationWindowState `json:"window,omitempty"`
+}
+
+// State returns the current paginator progress. Datetime params are stored as
+// formatted strings so the state survives a JSON round trip.
+func (p *Paginator) State() PaginatorState {
+	ctx := make(map[string]any, len(p.ctx))
+	for _, param := range p.config.Pagination.Params {
+		val, ok := p.ctx[param.Name]
+		if !ok {
+			continue
+		}
+		if t, isTime := val.(time.Time); isTime && param.Type == "datetime" {
+			val = t.Format(param.Format)
+		}
+		ctx[param.Name] = val
+	}
+	state := PaginatorState{
+		Ctx:         ctx,
+		PageNum:     p.pageNum,
+		NextPageUrl: p.nextPageUrl,
+		Stopped:     p.stopped,
+	}
+	if p.window != nil {
+		state.Window = p.window.state()
+	}
+	return state
+}
+
+// Restore resumes the paginator from a saved state. Whole numbers decoded from
+// JSON as float64 are converted back for int params.
+func (p *Paginator) Restore(state PaginatorState) {
+	for _, param := range p.config.Pagination.Params {
+		val, ok := state.Ctx[param.Name]
+		if !ok {
+			continue
+		}
+		if f, isFloat := val.(float64); isFloat && param.Type == "int" && f == math.Trunc(f) {
+			val = int(f)
+		}
+		p.ctx[param.Name] = val
+	}
+	if p.window != nil && state.Window != nil {
+		p.window.restore(state.Window)
+	}
+	p.pageNum = state.PageNum
+	p.nextPageUrl = state.NextPageUrl
+	p.stopped = state.Stopped
+}
+
 func (p *Paginator) Ctx() PaginationContext {
 	return p.ctx
 }
@@ -95,20 +313,17 @@ func (p *Paginator) PageNum() int {
 	return p.pageNum
 }
 
-func evalSimpleExpr(expression string, val interface{}) (interface{}, error) {
-	prog, err := expr.Compile(fmt.Sprintf("x %s", expression))
-	if err != nil {
-		return nil, err
-	}
+// evalIncrement applies a compiled increment (e.g. "+ 10") to val
+func evalIncrement(prog *vm.Program, val interface{}) (interface{}, error) {
 	return expr.Run(prog, map[string]interface{}{"x": val})
 }
 
-func evalJQ(expr string, input interface{}) (interface{}, error) {
-	query, err := gojq.Parse(expr)
-	if err != nil {
-		return nil, fmt.Errorf("parse error: %w", err)
+// evalJQ returns the first result of a compiled jq expression
+func evalJQ(code *CompiledJQ, input interface{}) (interface{}, error) {
+	if code == nil {
+		return nil, fmt.Errorf("missing jq expression")
 	}
-	iter := query.Run(input)
+	iter := code.Code.Run(input)
 
 	v, ok := iter.Next()
 	if !ok {
@@ -155,6 +370,12 @@ func (p *Paginator) initializeContext() error {
 func (p *Paginator) applyIncrements() error {
 	p.pageNum += 1
 
+	if p.window != nil {
+		if err := p.window.next(); err != nil {
+			return err
+		}
+	}
+
 	for _, param := range p.config.Pagination.Params {
 		if param.Type == "dynamic" {
 			continue
@@ -179,8 +400,8 @@ func (p *Paginator) applyIncrements() error {
 				p.ctx[param.Name] = newTime.Format(param.Format)
 
 			case "int", "float":
-				// Increment using jq math expression (e.g. `. + 10`)
-				res, err := evalSimpleExpr(param.Increment, val)
+				// Increment using a math expression (e.g. `+ 10`)
+				res, err := evalIncrement(p.compiled.Increments[param.Name], val)
 				if err != nil {
 					return fmt.Errorf("jq eval error on increment for '%s': %w", param.Name, err)
 				}
@@ -209,10 +430,11 @@ func (p *Paginator) extractDynamicParams(body interface{}, headers map[string][]
 
 		switch sourceType {
 		case "body":
-			if sourcePath == "" {
+			code := p.compiled.Sources[param.Name]
+			if code == nil {
 				return fmt.Errorf("missing jq expression for param '%s'", param.Name)
 			}
-			val, err := evalJQ(sourcePath, body)
+			val, err := evalJQ(code, body)
 			if err != nil {
 				return fmt.Errorf("jq error for %s: %w", param.Name, err)
 			}
@@ -233,7 +455,9 @@ func (p *Paginator) extractDynamicParams(body interface{}, headers map[string][]
 	return nil
 }
 
-func (p *Paginator) extractNextUrl(body interface{}, headers map[string][]string) error {
+// extractNextUrl sets the next page URL from the NextPageUrlSelector source.
+// Relative targets of link:<rel> sources are resolved against requestURL.
+func (p *Paginator) extractNextUrl(body interface{}, headers map[string][]string, requestURL *url.URL) error {
 	if len(p.config.Pagination.NextPageUrlSelector) == 0 {
 		return nil
 	}
@@ -246,10 +470,10 @@ func (p *Paginator) extractNextUrl(body interface{}, headers map[string][]string
 	}
 	switch sourceType {
 	case "body":
-		if sourcePath == "" {
+		if p.compiled.NextPageUrl == nil {
 			return fmt.Errorf("missing jq expression for next url")
 		}
-		val, err := evalJQ(sourcePath, body)
+		val, err := evalJQ(p.compiled.NextPageUrl, body)
 		if err != nil {
 			return fmt.Errorf("jq error for next url: %w", err)
 		}
@@ -269,6 +493,16 @@ func (p *Paginator) extractNextUrl(body interface{}, headers map[string][]string
 			p.nextPageUrl = ""
 		}
 
+	case "link":
+		if sourcePath == "" {
+			return fmt.Errorf("missing link relation for next url")
+		}
+		if target, ok := LinkHeaderTarget(http.Header(headers).Values("Link"), sourcePath, requestURL); ok {
+			p.nextPageUrl = NormalizeURL(target)
+		} else {
+			p.nextPageUrl = ""
+		}
+
 	default:
 		return fmt.Errorf("unsupported source type '%s' for next url", sourceType)
 	}
@@ -392,6 +626,9 @@ func toFloat64(v any) (float64, error) {
 		return float64(t), nil
 	case float64:
 		return t, nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(t).Float64()
+		return f, nil
 	case string:
 		return strconv.ParseFloat(t, 64)
 	default:
@@ -399,9 +636,13 @@ func toFloat64(v any) (float64, error) {
 	}
 }
 
+var (
+	smartDurationPattern = regexp.MustCompile(`(?i)([+-]?\d+)([yMwdhms])`)
+	nowOffsetPattern     = regexp.MustCompile(`^([+-])\s*(\d+[a-zA-Z]*)$`) // e.g. "+1d", "- 2h"
+)
+
 func addSmartDuration(t time.Time, expr string) (time.Time, error) {
-	re := regexp.MustCompile(`(?i)([+-]?\d+)([yMwdhms])`)
-	matches := re.FindAllStringSubmatch(expr, -1)
+	matches := smartDurationPattern.FindAllStringSubmatch(expr, -1)
 	if matches == nil {
 		return t, fmt.Errorf("invalid duration: %s", expr)
 	}
@@ -441,9 +682,7 @@ func toTime(value any, format string) (time.Time, error) {
 				return now, nil
 			}
 
-			// Matches things like "+1d", "- 2h", etc.
-			re := regexp.MustCompile(`^([+-])\s*(\d+[a-zA-Z]*)$`)
-			matches := re.FindStringSubmatch(offset)
+			matches := nowOffsetPattern.FindStringSubmatch(offset)
 			if len(matches) != 3 {
 				return time.Time{}, fmt.Errorf("invalid now offset: %s", offset)
 			}
@@ -471,85 +710,128 @@ func toTime(value any, format string) (time.Time, error) {
 	}
 }
 
-func (p *Paginator) shouldStop(body interface{}) (bool, error) {
+func (p *Paginator) shouldStop(body interface{}, headers map[string][]string) (bool, error) {
+	// stop once the last time window has been requested
+	if p.window != nil && p.window.finished {
+		return true, nil
+	}
+
 	// stop immediately if NextPageUrlSelector is specified but no next token is found
 	if p.config.Pagination.NextPageUrlSelector != "" && p.nextPageUrl == "" {
 		return true, nil
 	}
 
-	for _, cond := range p.config.Pagination.StopOn {
-		switch cond.Type {
-		case "pageNum":
-			return p.pageNum >= cond.Value.(int), nil
-		case "responseBody":
-			res, err := evalJQ(cond.Expression, body)
-			if err != nil {
-				return false, err
-			}
-			if b, ok := res.(bool); ok && b {
-				return true, nil
-			}
-
-		case "requestParam":
-			paramLoc, paramName, err := parseParamPath(cond.Param)
-			if err != nil {
-				return false, err
-			}
-			// Lookup param definition for correct type/format
-			var paramDef *Param
-			for _, pdef := range p.config.Pagination.Params {
-				if pdef.Location == paramLoc && pdef.Name == paramName {
-					paramDef = &pdef
-					break
-				}
-			}
-			if paramDef == nil {
-				return false, fmt.Errorf("param definition not found for %s", cond.Param)
-			}
-
-			val := p.ctx[paramName]
-			if val == nil {
-				continue
-			}
-
-			ok, err := compareValues(*paramDef, val, cond.Value, cond.Compare)
-			if err != nil {
-				return false, err
-			}
-			if ok {
-				return true, nil
-			}
+	// stopOn conditions are alternatives: the first match stops the pagination
+	if len(p.compiled.stops) == 0 {
+		return false, nil
+	}
+	env := p.stopEnv(body, headers)
+	for _, stop := range p.compiled.stops {
+		ok, err := stop.matches(p, body, env)
+		if err != nil || ok {
+			return ok, err
 		}
 	}
 	return false, nil
 }
 
+// TotalPages evaluates the total selector on a response body and returns the
+// number of pages, counting from page 0
+func (p *Paginator) TotalPages(body interface{}) (int, error) {
+	total := p.config.Pagination.Total
+	if total == nil {
+		return 0, fmt.Errorf("pagination total is not configured")
+	}
+	val, err := evalJQ(p.compiled.Total, body)
+	if err != nil {
+		return 0, fmt.Errorf("jq error for pagination total: %w", err)
+	}
+	if val == nil {
+		return 0, fmt.Errorf("pagination total selector '%s' returned null", total.Selector)
+	}
+	n, err := toFloat64(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pagination total: %w", err)
+	}
+	if total.Unit == PaginationTotalItems {
+		if total.PageSize <= 0 {
+			return 0, fmt.Errorf("pagination total pageSize must be positive")
+		}
+		n = math.Ceil(n / float64(total.PageSize))
+	}
+	return int(n), nil
+}
+
+// Advance moves to the next page without a response and returns its params.
+// Only meaningful when no param depends on the response, as with Total.
+func (p *Paginator) Advance() (*RequestParts, error) {
+	if err := p.applyIncrements(); err != nil {
+		return nil, err
+	}
+	return p.RequestParts()
+}
+
+// NextFromCtx returns the query/body/header params for the current page.
+//
+// Deprecated: use RequestParts, which reports param template errors.
+// NextFromCtx omits params whose template fails to render.
 func (p *Paginator) NextFromCtx() *RequestParts {
+	parts, _ := p.requestParts()
+	return parts
+}
+
+// RequestParts returns the query/body/header params for the current page
+func (p *Paginator) RequestParts() (*RequestParts, error) {
+	parts, errs := p.requestParts()
+	return parts, errors.Join(errs...)
+}
+
+// requestParts builds the request params, collecting template errors
+func (p *Paginator) requestParts() (*RequestParts, []error) {
 	q := make(map[string]string)
 	h := make(map[string]string)
 	b := make(map[string]interface{})
 
+	var templateCtx map[string]any
+	if len(p.compiled.Templates) > 0 {
+		templateCtx = p.State().Ctx
+	}
+
+	var errs []error
 	for _, param := range p.config.Pagination.Params {
 		val, exists := p.ctx[param.Name]
 		if param.Type == "dynamic" && !exists {
 			continue
 		}
+		str := fmt.Sprintf("%v", val)
+		if tmpl, ok := p.compiled.Templates[param.Name]; ok && param.Location != "body" {
+			rendered, err := tmpl.Execute(templateCtx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("param '%s': %w", param.Name, err))
+				continue
+			}
+			str = rendered
+		}
 		switch param.Location {
 		case "query":
-			q[param.Name] = fmt.Sprintf("%v", val)
+			q[param.Name] = str
 		case "header":
-			h[param.Name] = fmt.Sprintf("%v", val)
+			h[param.Name] = str
 		case "body":
 			b[param.Name] = val
 		}
 	}
 
+	if p.window != nil {
+		p.window.addParams(q, h, b)
+	}
+
 	return &RequestParts{
 		QueryParams: q,
 		BodyParams:  b,
 		Headers:     h,
 		NextPageUrl: p.nextPageUrl,
-	}
+	}, errs
 }
 
 // Next advances the paginator and returns query/body/header params for the next request
@@ -568,18 +850,35 @@ func (p *Paginator) Next(resp *http.Response) (*RequestParts, bool, error) {
 	resp.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
 
 	// Step 3: Decode into JSON
-	var bodyJSON interface{}
-	if err := json.Unmarshal(buf.Bytes(), &bodyJSON); err != nil {
+	bodyJSON, err := decodeJSON(buf.Bytes(), p.config.NumberMode != NumberModeFloat)
+	if err != nil {
 		return nil, false, fmt.Errorf("failed to decode body: %w", err)
 	}
 
+	return p.NextWithBody(resp, bodyJSON)
+}
+
+// NextWithBody advances the paginator using an already decoded response body.
+// Used when the body is not JSON (see RequestConfig.ResponseFormat); the response
+// body itself is not read.
+func (p *Paginator) NextWithBody(resp *http.Response, bodyJSON interface{}) (*RequestParts, bool, error) {
+	if p.stopped {
+		return nil, true, nil
+	}
+
 	headers := map[string][]string(resp.Header)
 
+	p.rememberRequest()
+
 	if err := p.extractDynamicParams(bodyJSON, headers); err != nil {
 		return nil, false, err
 	}
 
-	if err := p.extractNextUrl(bodyJSON, headers); err != nil {
+	var requestURL *url.URL
+	if resp.Request != nil {
+		requestURL = resp.Request.URL
+	}
+	if err := p.extractNextUrl(bodyJSON, headers, requestURL); err != nil {
 		return nil, false, err
 	}
 
@@ -587,7 +886,7 @@ func (p *Paginator) Next(resp *http.Response) (*RequestParts, bool, error) {
 		return nil, false, err
 	}
 
-	stop, err := p.shouldStop(bodyJSON)
+	stop, err := p.shouldStop(bodyJSON, headers)
 	if err != nil {
 		return nil, false, err
 	}
@@ -596,6 +895,14 @@ func (p *Paginator) Next(resp *http.Response) (*RequestParts, bool, error) {
 		return nil, true, nil
 	}
 
-	return p.NextFromCtx(), false, nil
+	if err := p.checkLoop(); err != nil {
+		return nil, false, err
+	}
+
+	parts, err := p.RequestParts()
+	if err != nil {
+		return nil, false, err
+	}
+	return parts, false, nil
 }
 
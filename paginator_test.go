@@ -242,3 +242,268 @@ func TestDynamicParamLifecycle(t *testing.T) {
 func TestNextUrlEncodingLifecycle(t *testing.T) {
 	runPaginatorTest(t, "testdata/paginator/test11_next_url_encoding.yaml", 2)
 }
+
+func TestNextUrlLinkHeader(t *testing.T) {
+	runPaginatorTest(t, "testdata/paginator/test12_link_header.yaml", 3)
+}
+
+func TestStopConditionGroups(t *testing.T) {
+	runPaginatorTest(t, "testdata/paginator/test14_stop_groups.yaml", 3)
+}
+
+func TestStopConditionsCheckEveryCondition(t *testing.T) {
+	// A pageNum condition that does not match yet must not hide the next ones
+	p, err := NewPaginator(ConfigP{
+		Pagination: Pagination{
+			Params: []Param{
+				{Name: "page", Location: "query", Type: "int", Default: "1", Increment: "+ 1"},
+			},
+			StopOn: []StopCondition{
+				{Type: "pageNum", Value: float64(5)},
+				{Type: "expression", Expression: "body.last"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, done, err := p.NextWithBody(&http.Response{}, map[string]any{"last": false})
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	_, done, err = p.NextWithBody(&http.Response{}, map[string]any{"last": true})
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestStopExpressionMustReturnBool(t *testing.T) {
+	p, err := NewPaginator(ConfigP{
+		Pagination: Pagination{
+			Params: []Param{
+				{Name: "page", Location: "query", Type: "int", Default: "1", Increment: "+ 1"},
+			},
+			StopOn: []StopCondition{{Type: "expression", Expression: "body.next"}},
+		},
+	})
+	require.NoError(t, err)
+
+	_, _, err = p.NextWithBody(&http.Response{}, map[string]any{"next": "abc"})
+	assert.ErrorContains(t, err, "must return a bool")
+}
+
+func TestCompileStopConditions(t *testing.T) {
+	params := []Param{{Name: "offset", Location: "query", Type: "int", Default: "0"}}
+	for name, tt := range map[string]struct {
+		cond StopCondition
+		err  string
+	}{
+		"valid groups": {cond: StopCondition{Any: []StopCondition{
+			{Type: "requestParam", Param: ".query.offset", Compare: "gte", Value: 100},
+			{All: []StopCondition{{Type: "pageNum", Value: 3}, {Type: "expression", Expression: "len(body) == 0"}}},
+		}}},
+		"unknown variable":  {cond: StopCondition{Type: "expression", Expression: "page > 3"}, err: "stopOn[0]: invalid expression"},
+		"broken jq":         {cond: StopCondition{Type: "responseBody", Expression: ".items |"}, err: "stopOn[0]: invalid jq expression"},
+		"nested error path": {cond: StopCondition{All: []StopCondition{{Type: "pageNum", Value: 1}, {Type: "pageNum", Value: 2.5}}}, err: "stopOn[0].all[1]: pageNum value must be a whole number"},
+		"unknown param":     {cond: StopCondition{Type: "requestParam", Param: ".query.page", Compare: "gt", Value: 3}, err: "param definition not found"},
+	} {
+		_, err := compileStopConditions([]StopCondition{tt.cond}, params)
+		if tt.err == "" {
+			assert.NoError(t, err, name)
+			continue
+		}
+		assert.ErrorContains(t, err, tt.err, name)
+	}
+
+	// Broken stop conditions are reported when the config is loaded
+	_, validationErrors, err := ValidateAndCompile(Config{
+		RootContext: []interface{}{},
+		Steps: []Step{{
+			Type: "request",
+			Request: &RequestConfig{URL: "https://api.example.com", Method: "GET", Pagination: Pagination{
+				Params: params,
+				StopOn: []StopCondition{{Type: "expression", Expression: "body.items =="}},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, validationErrors, 1)
+	assert.Equal(t, "compilation", validationErrors[0].Location)
+	assert.Contains(t, validationErrors[0].Message, "stopOn[0]")
+}
+
+func TestCompilePagination(t *testing.T) {
+	cp, err := compilePagination(Pagination{
+		NextPageUrlSelector: "body:.links.next",
+		Params: []Param{
+			{Name: "offset", Location: "query", Type: "int", Default: "0", Increment: "+ 10"},
+			{Name: "since", Location: "query", Type: "datetime", Format: time.RFC3339, Default: "now", Increment: "1d"},
+			{Name: "token", Location: "query", Type: "dynamic", Source: "body:.token"},
+			{Name: "etag", Location: "header", Type: "dynamic", Source: "header:ETag"},
+		},
+		Total: &PaginationTotal{Selector: ".meta.pages"},
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, cp.NextPageUrl)
+	assert.Len(t, cp.Increments, 1)
+	assert.Contains(t, cp.Increments, "offset")
+	assert.Len(t, cp.Sources, 1)
+	assert.Contains(t, cp.Sources, "token")
+	assert.NotNil(t, cp.Total)
+
+	for name, tt := range map[string]struct {
+		pagination Pagination
+		err        string
+	}{
+		"next url": {Pagination{NextPageUrlSelector: "body:.next |"}, "nextPageUrlSelector: invalid jq expression"},
+		"source": {Pagination{Params: []Param{
+			{Name: "token", Location: "query", Type: "dynamic", Source: "body:.token["},
+		}}, "source of param 'token'"},
+		"int increment": {Pagination{Params: []Param{
+			{Name: "offset", Location: "query", Type: "int", Default: "0", Increment: "+"},
+		}}, "invalid increment for param 'offset'"},
+		"datetime increment": {Pagination{Params: []Param{
+			{Name: "since", Location: "query", Type: "datetime", Format: time.RFC3339, Default: "now", Increment: "one day"},
+		}}, "invalid increment for param 'since'"},
+		"total": {Pagination{Total: &PaginationTotal{Selector: ".meta..pages"}}, "total: invalid jq expression"},
+	} {
+		_, err := compilePagination(tt.pagination)
+		assert.ErrorContains(t, err, tt.err, name)
+	}
+}
+
+func TestValidatePaginationStopGroups(t *testing.T) {
+	errs := validatePaginationStop(StopCondition{
+		Type: "pageNum",
+		All:  []StopCondition{{Type: "expression"}, {Type: "pageNum", Value: "three"}},
+		Any:  []StopCondition{{Type: "pageNum", Value: float64(3)}},
+	}, "stopOn[0]")
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"stopOn[0].type",
+		"stopOn[0]",
+		"stopOn[0].all[0].expression",
+		"stopOn[0].all[1].value",
+	}, locations)
+}
+
+func TestParamTemplate(t *testing.T) {
+	p, err := NewPaginator(ConfigP{
+		Pagination: Pagination{
+			Params: []Param{
+				{
+					Name:      "Range",
+					Location:  "header",
+					Type:      "int",
+					Default:   "0",
+					Increment: "+ 100",
+					Template:  "items={{ .Range }}-{{ add .Range 99 }}",
+				},
+			},
+			StopOn: []StopCondition{
+				{Type: "responseBody", Expression: "length < 100"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	parts, err := p.RequestParts()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Range": "items=0-99"}, parts.Headers)
+
+	require.NoError(t, p.applyIncrements())
+	parts, err = p.RequestParts()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Range": "items=100-199"}, parts.Headers)
+
+	_, err = NewPaginator(ConfigP{Pagination: Pagination{Params: []Param{
+		{Name: "Range", Location: "header", Type: "int", Default: "0", Template: "{{ .Range "},
+	}}})
+	assert.Error(t, err)
+}
+
+func TestValidateNextPageUrlSelector(t *testing.T) {
+	for selector, valid := range map[string]bool{
+		"body:.next":  true,
+		"header:Next": true,
+		"link:next":   true,
+		"link:":       false,
+		"jq:.next":    false,
+	} {
+		errs := validatePagination(Pagination{NextPageUrlSelector: selector}, "pagination")
+		if valid {
+			assert.Empty(t, errs, selector)
+			continue
+		}
+		require.Len(t, errs, 1, selector)
+		assert.Equal(t, "pagination.nextPageUrlSelector", errs[0].Location)
+	}
+}
+
+func TestValidateSelectorOnlyPagination(t *testing.T) {
+	for _, selector := range []string{"bogus:next", "body:"} {
+		cfg := Config{
+			RootContext: []interface{}{},
+			Steps: []Step{{
+				Type: "request",
+				Request: &RequestConfig{
+					URL:        "https://api.example.com",
+					Method:     "GET",
+					Pagination: Pagination{NextPageUrlSelector: selector},
+				},
+			}},
+		}
+		errs := ValidateConfig(cfg)
+		require.Len(t, errs, 1, selector)
+		assert.Equal(t, "steps[0].request.pagination.nextPageUrlSelector", errs[0].Location)
+
+		_, errs, _ = ValidateAndCompile(cfg)
+		assert.NotEmpty(t, errs, selector)
+	}
+}
+
+func TestTotalPagesAndAdvance(t *testing.T) {
+	p, err := NewPaginator(ConfigP{
+		Pagination: Pagination{
+			Params: []Param{
+				{Name: "offset", Location: "query", Type: "int", Default: "0", Increment: "+ 20"},
+			},
+			Total: &PaginationTotal{Selector: ".meta.count", Unit: PaginationTotalItems, PageSize: 20},
+		},
+	})
+	require.NoError(t, err)
+
+	total, err := p.TotalPages(map[string]any{"meta": map[string]any{"count": float64(41)}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	_, err = p.TotalPages(map[string]any{"meta": map[string]any{}})
+	assert.ErrorContains(t, err, "returned null")
+
+	parts, err := p.Advance()
+	require.NoError(t, err)
+	assert.Equal(t, "20", parts.QueryParams["offset"])
+	assert.Equal(t, 1, p.PageNum())
+}
+
+func TestPaginatorNumberMode(t *testing.T) {
+	pagination := Pagination{Params: []Param{
+		{Name: "cursor", Location: "query", Type: "dynamic", Source: "body:.next"},
+	}}
+	for mode, expected := range map[string]string{
+		"":              "9007199254740993",
+		NumberModeExact: "9007199254740993",
+		NumberModeFloat: "9.007199254740992e+15",
+	} {
+		p, err := NewPaginator(ConfigP{Pagination: pagination, NumberMode: mode})
+		require.Nil(t, err)
+		parts, done, err := p.Next(&http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"next": 9007199254740993}`)),
+		})
+		require.Nil(t, err)
+		require.False(t, done)
+		assert.Equal(t, expected, parts.QueryParams["cursor"], mode)
+	}
+}
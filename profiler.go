@@ -60,6 +60,12 @@ const (
 
 	// Errors
 	EVENT_ERROR
+
+	// Request step sub-events (appended to keep existing values stable)
+	EVENT_REQUEST_RETRY
+
+	// Step condition (when) evaluation
+	EVENT_STEP_CONDITION
 )
 
 type StepProfilerData struct {
@@ -254,6 +260,7 @@ type URLCompositionData struct {
 	URLTemplate     string
 	PageNumber      int
 	QueryParams     map[string]string
+	HeaderParams    map[string]string
 	BodyParams      map[string]interface{}
 	NextPageURL     string
 	TemplateContext map[string]any
@@ -272,10 +279,11 @@ func (p *Profiler) EmitURLComposition(pageID string, step Step, data URLComposit
 	event.Data = map[string]any{
 		"urlTemplate": data.URLTemplate,
 		"paginationState": map[string]any{
-			"pageNumber":  data.PageNumber,
-			"queryParams": data.QueryParams,
-			"bodyParams":  data.BodyParams,
-			"nextPageUrl": data.NextPageURL,
+			"pageNumber":   data.PageNumber,
+			"queryParams":  data.QueryParams,
+			"headerParams": data.HeaderParams,
+			"bodyParams":   data.BodyParams,
+			"nextPageUrl":  data.NextPageURL,
 		},
 		"goTemplateContext": data.TemplateContext,
 		"resultUrl":         data.ResultURL,
@@ -580,6 +588,22 @@ func (p *Profiler) EmitValueSelection(parentID string, step Step, index int, val
 	return event.ID
 }
 
+// EmitValueSelectionWithWorker emits forValues value selection event with worker tracking
+func (p *Profiler) EmitValueSelectionWithWorker(parentID string, step Step, index int, value any, contextKey string, workerID int, workerPool string) string {
+	if !p.Enabled() {
+		return ""
+	}
+
+	event := newEventWithWorker(EVENT_ITEM_SELECTION, fmt.Sprintf("Value %d", index), parentID, step, workerID, workerPool)
+	event.Data = map[string]any{
+		"iterationIndex":    index,
+		"itemValue":         copyDataSafe(value),
+		"currentContextKey": contextKey,
+	}
+	p.emit(event)
+	return event.ID
+}
+
 // =============================================================================
 // Parallelism Events
 // =============================================================================
@@ -627,6 +651,65 @@ func (p *Profiler) EmitStreamResult(parentID string, step Step, entity any, inde
 		"entity": copyDataSafe(entity),
 		"index":  index,
 	}
+	if step.Output != "" {
+		event.Data["output"] = step.Output
+	}
+	p.emit(event)
+}
+
+// =============================================================================
+// Retry Events
+// =============================================================================
+
+// RequestRetryData holds data for request retry event
+type RequestRetryData struct {
+	Attempt     int
+	MaxAttempts int
+	StatusCode  int // 0 for transport errors
+	Reason      string
+	DelayMs     int64
+}
+
+// EmitRequestRetry emits request retry event for a failed attempt that will be retried
+func (p *Profiler) EmitRequestRetry(pageID string, step Step, data RequestRetryData) {
+	if !p.Enabled() {
+		return
+	}
+
+	event := newEvent(EVENT_REQUEST_RETRY, fmt.Sprintf("Retry %d/%d", data.Attempt, data.MaxAttempts-1), pageID, step)
+	event.Data = map[string]any{
+		"attempt":     data.Attempt,
+		"maxAttempts": data.MaxAttempts,
+		"reason":      data.Reason,
+		"delayMs":     data.DelayMs,
+	}
+	if data.StatusCode != 0 {
+		event.Data["statusCode"] = data.StatusCode
+	}
+	p.emit(event)
+}
+
+// =============================================================================
+// Condition Events
+// =============================================================================
+
+// EmitStepCondition emits step condition event with the evaluation outcome
+func (p *Profiler) EmitStepCondition(parentID string, step Step, expression string, run bool) {
+	if !p.Enabled() {
+		return
+	}
+
+	name := "Condition: evaluated"
+	if !run {
+		name = "Condition: skipped"
+	}
+
+	event := newEvent(EVENT_STEP_CONDITION, name, parentID, step)
+	event.Data = map[string]any{
+		"expression": expression,
+		"result":     run,
+		"skipped":    !run,
+	}
 	p.emit(event)
 }
 
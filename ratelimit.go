@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitsConfig limits all HTTP requests of a crawler, including
+// authentication requests, regardless of the step that issues them
+type RateLimitsConfig struct {
+	MaxInFlight int             `yaml:"maxInFlight,omitempty" json:"maxInFlight,omitempty"` // maximum concurrent requests across all hosts (0 = unlimited)
+	Hosts       []HostRateLimit `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+}
+
+// HostRateLimit limits the requests matching a host or URL pattern.
+// Match is an exact host ("api.example.com", or "api.example.com:8080" to
+// include the port), a host wildcard ("*.example.com") or a URL prefix
+// ("https://api.example.com/v2/"). The first matching entry applies.
+type HostRateLimit struct {
+	Match             string  `yaml:"match" json:"match"`
+	RequestsPerSecond float64 `yaml:"requestsPerSecond,omitempty" json:"requestsPerSecond,omitempty"`
+	Burst             int     `yaml:"burst,omitempty" json:"burst,omitempty"`                   // default: 1
+	MaxConcurrency    int     `yaml:"maxConcurrency,omitempty" json:"maxConcurrency,omitempty"` // 0 = unlimited
+}
+
+// matches reports whether the request URL matches the pattern
+func (h HostRateLimit) matches(req *http.Request) bool {
+	switch {
+	case strings.Contains(h.Match, "://"):
+		return strings.HasPrefix(req.URL.String(), h.Match)
+	case strings.HasPrefix(h.Match, "*."):
+		host := strings.ToLower(req.URL.Hostname())
+		return strings.HasSuffix(host, strings.ToLower(h.Match[1:]))
+	case strings.Contains(h.Match, ":"):
+		return strings.EqualFold(req.URL.Host, h.Match)
+	}
+	return strings.EqualFold(req.URL.Hostname(), h.Match)
+}
+
+// hostLimiter enforces a single HostRateLimit entry
+type hostLimiter struct {
+	config  HostRateLimit
+	limiter *rate.Limiter // nil when there is no rate limit
+	slots   chan struct{} // nil when concurrency is unlimited
+}
+
+// requestLimiter enforces the rateLimits section for every request of a
+// crawler. It is shared by all steps and runs, so nested parallel steps
+// cannot multiply the budget.
+type requestLimiter struct {
+	global chan struct{} // nil when maxInFlight is unlimited
+	hosts  []*hostLimiter
+}
+
+// newRequestLimiter creates the limiter for the given config (nil config = no limits)
+func newRequestLimiter(cfg *RateLimitsConfig) *requestLimiter {
+	if cfg == nil {
+		return nil
+	}
+	l := &requestLimiter{}
+	if cfg.MaxInFlight > 0 {
+		l.global = make(chan struct{}, cfg.MaxInFlight)
+	}
+	for _, h := range cfg.Hosts {
+		hl := &hostLimiter{config: h}
+		if h.RequestsPerSecond > 0 {
+			burst := h.Burst
+			if burst <= 0 {
+				burst = 1
+			}
+			hl.limiter = rate.NewLimiter(rate.Limit(h.RequestsPerSecond), burst)
+		}
+		if h.MaxConcurrency > 0 {
+			hl.slots = make(chan struct{}, h.MaxConcurrency)
+		}
+		l.hosts = append(l.hosts, hl)
+	}
+	return l
+}
+
+// acquire waits until the request may be sent and returns the function that
+// releases its slots. The host slot is taken before the global one, so requests
+// queued for a busy host never hold up other hosts.
+func (l *requestLimiter) acquire(ctx context.Context, req *http.Request) (func(), error) {
+	var held []chan struct{}
+	release := func() {
+		for _, slots := range held {
+			<-slots
+		}
+	}
+	take := func(slots chan struct{}) error {
+		if slots == nil {
+			return nil
+		}
+		select {
+		case slots <- struct{}{}:
+			held = append(held, slots)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for _, h := range l.hosts {
+		if !h.config.matches(req) {
+			continue
+		}
+		if err := take(h.slots); err != nil {
+			return nil, err
+		}
+		if h.limiter != nil {
+			if err := h.limiter.Wait(ctx); err != nil {
+				release()
+				return nil, err
+			}
+		}
+		break
+	}
+
+	if err := take(l.global); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wrap returns a client that applies the limits to every request sent through
+// client. A request is in flight until its response headers are received.
+// An *http.Client is cloned with a limiting transport, so it can still be
+// handed to libraries that require one (e.g. the OAuth2 token source).
+func (l *requestLimiter) wrap(client HTTPClient) HTTPClient {
+	if l == nil {
+		return client
+	}
+	if hc, ok := client.(*http.Client); ok {
+		clone := *hc
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		clone.Transport = &rateLimitedTransport{base: base, limiter: l}
+		return &clone
+	}
+	return &rateLimitedClient{base: client, limiter: l}
+}
+
+// rateLimitedTransport applies a requestLimiter to an http.RoundTripper
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *requestLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return t.base.RoundTrip(req)
+}
+
+// rateLimitedClient applies a requestLimiter to any HTTPClient
+type rateLimitedClient struct {
+	base    HTTPClient
+	limiter *requestLimiter
+}
+
+func (c *rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	release, err := c.limiter.acquire(req.Context(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.base.Do(req)
+}
@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peakTransport answers every request with an empty JSON array after delay,
+// tracking the peak number of concurrent requests per host
+type peakTransport struct {
+	delay time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	peak     map[string]int
+	total    int32
+	maxTotal int32
+}
+
+func (p *peakTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	if p.inFlight == nil {
+		p.inFlight, p.peak = map[string]int{}, map[string]int{}
+	}
+	p.inFlight[req.URL.Host]++
+	p.peak[req.URL.Host] = max(p.peak[req.URL.Host], p.inFlight[req.URL.Host])
+	p.total++
+	p.maxTotal = max(p.maxTotal, p.total)
+	p.mu.Unlock()
+
+	time.Sleep(p.delay)
+
+	p.mu.Lock()
+	p.inFlight[req.URL.Host]--
+	p.total--
+	p.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`[]`)),
+		Request:    req,
+	}, nil
+}
+
+func TestHostRateLimitMatches(t *testing.T) {
+	tests := []struct {
+		match string
+		url   string
+		want  bool
+	}{
+		{"api.example.com", "https://api.example.com/items", true},
+		{"API.example.com", "https://api.example.com:8443/items", true},
+		{"api.example.com", "https://other.example.com/items", false},
+		{"api.example.com:8443", "https://api.example.com:8443/items", true},
+		{"api.example.com:8443", "https://api.example.com/items", false},
+		{"*.example.com", "https://eu.api.example.com/items", true},
+		{"*.example.com", "https://example.com/items", false},
+		{"https://api.example.com/v2/", "https://api.example.com/v2/items?page=1", true},
+		{"https://api.example.com/v2/", "https://api.example.com/v1/items", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", tt.url, nil)
+		require.Nil(t, err)
+		assert.Equal(t, tt.want, HostRateLimit{Match: tt.match}.matches(req), "%s vs %s", tt.match, tt.url)
+	}
+}
+
+func TestRequestLimiterCancelledWhileWaiting(t *testing.T) {
+	limiter := newRequestLimiter(&RateLimitsConfig{Hosts: []HostRateLimit{{Match: "api.example.com", MaxConcurrency: 1}}})
+	req, err := http.NewRequest("GET", "https://api.example.com/items", nil)
+	require.Nil(t, err)
+
+	release, err := limiter.acquire(context.Background(), req)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = limiter.acquire(ctx, req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release, err = limiter.acquire(context.Background(), req)
+	require.Nil(t, err, "the slot is free again after release")
+	release()
+}
+
+func TestRateLimitsNestedParallelism(t *testing.T) {
+	configContent := `
+rootContext: []
+rateLimits:
+  maxInFlight: 4
+  hosts:
+    - match: a.example.com
+      maxConcurrency: 2
+steps:
+  - type: forValues
+    values: [1, 2, 3, 4]
+    as: outer
+    parallelism:
+      maxConcurrency: 4
+    steps:
+      - type: forValues
+        values: [1, 2, 3, 4]
+        as: inner
+        parallelism:
+          maxConcurrency: 4
+        steps:
+          - type: request
+            request:
+              url: https://a.example.com/items/{{ .outer }}/{{ .inner }}
+              method: GET
+            noopMerge: true
+          - type: request
+            request:
+              url: https://b.example.com/items/{{ .outer }}/{{ .inner }}
+              method: GET
+            noopMerge: true
+`
+	transport := &peakTransport{delay: 10 * time.Millisecond}
+	craw := newTestCrawler(t, configContent, &http.Client{Transport: transport})
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	assert.LessOrEqual(t, transport.peak["a.example.com"], 2, "host maxConcurrency is shared by all nested workers")
+	assert.LessOrEqual(t, int(transport.maxTotal), 4, "maxInFlight bounds all hosts")
+	assert.Greater(t, transport.peak["b.example.com"], 1, "unmatched hosts are only bound by maxInFlight")
+}
+
+func TestRateLimitsApplyToAuthentication(t *testing.T) {
+	configContent := `
+rootContext: []
+rateLimits:
+  hosts:
+    - match: api.provider.com
+      requestsPerSecond: 20
+auth:
+  type: custom
+  loginRequest:
+    url: https://api.provider.com/v1/authenticate
+    method: POST
+  extractFrom: body
+  extractSelector: .apiKey
+  injectInto: query
+  injectKey: api_key
+steps:
+  - type: forValues
+    values: [1, 2, 3]
+    as: id
+    parallelism:
+      maxConcurrency: 3
+    steps:
+      - type: request
+        request:
+          url: https://api.provider.com/v1/sensors/{{ .id }}
+          method: GET
+        noopMerge: true
+`
+	var mu sync.Mutex
+	var paths []string
+	var times []time.Time
+	var calls int32
+	// A plain HTTPClient (not *http.Client) is limited through a wrapper
+	client := jsonClient(func(req *http.Request) string {
+		atomic.AddInt32(&calls, 1)
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		times = append(times, time.Now())
+		mu.Unlock()
+		if req.URL.Path == "/v1/authenticate" {
+			return `{"apiKey": "secret"}`
+		}
+		return `[]`
+	})
+	craw := newTestCrawler(t, configContent, client)
+
+	require.Nil(t, craw.Run(context.TODO(), nil))
+
+	require.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.Equal(t, "/v1/authenticate", paths[0])
+	for i := 1; i < len(times); i++ {
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 40*time.Millisecond, "request %d should wait for the shared limiter", i)
+	}
+}
+
+func TestValidateRateLimits(t *testing.T) {
+	errs := validateRateLimits(RateLimitsConfig{
+		MaxInFlight: -1,
+		Hosts: []HostRateLimit{
+			{Match: "api.example.com", RequestsPerSecond: 5},
+			{RequestsPerSecond: -1, MaxConcurrency: -2},
+		},
+	}, "rateLimits")
+	locations := []string{}
+	for _, e := range errs {
+		locations = append(locations, e.Location)
+	}
+	assert.Equal(t, []string{
+		"rateLimits.maxInFlight",
+		"rateLimits.hosts[1].match",
+		"rateLimits.hosts[1].requestsPerSecond",
+		"rateLimits.hosts[1].maxConcurrency",
+	}, locations)
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryConfig configures how failed HTTP requests are retried.
+// It can be set globally on Config and overridden per request step.
+type RetryConfig struct {
+	MaxAttempts       int      `yaml:"maxAttempts,omitempty" json:"maxAttempts,omitempty"`             // total attempts including the first one (default: 3)
+	StatusCodes       []int    `yaml:"statusCodes,omitempty" json:"statusCodes,omitempty"`             // retryable status codes (default: 429, 502, 503, 504)
+	InitialBackoff    string   `yaml:"initialBackoff,omitempty" json:"initialBackoff,omitempty"`       // delay before the first retry (default: 500ms)
+	MaxBackoff        string   `yaml:"maxBackoff,omitempty" json:"maxBackoff,omitempty"`               // upper bound for any delay (default: 30s)
+	Multiplier        float64  `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`               // backoff growth factor (default: 2)
+	Jitter            *float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`                       // random +/- fraction applied to each delay (default: 0.2)
+	RespectRetryAfter *bool    `yaml:"respectRetryAfter,omitempty" json:"respectRetryAfter,omitempty"` // honor the Retry-After header (default: true)
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 30 * time.Second
+	defaultRetryMultiplier     = 2.0
+	defaultRetryJitter         = 0.2
+)
+
+// retryPolicy is the resolved, ready-to-use form of a RetryConfig
+type retryPolicy struct {
+	maxAttempts       int
+	statusCodes       map[int]bool
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	multiplier        float64
+	jitter            float64
+	respectRetryAfter bool
+}
+
+// newRetryPolicy resolves a RetryConfig into a retryPolicy, applying defaults.
+// Returns nil if cfg is nil (no retries).
+func newRetryPolicy(cfg *RetryConfig) (*retryPolicy, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	p := &retryPolicy{
+		maxAttempts:       defaultRetryMaxAttempts,
+		statusCodes:       make(map[int]bool),
+		initialBackoff:    defaultRetryInitialBackoff,
+		maxBackoff:        defaultRetryMaxBackoff,
+		multiplier:        defaultRetryMultiplier,
+		jitter:            defaultRetryJitter,
+		respectRetryAfter: true,
+	}
+
+	if cfg.MaxAttempts > 0 {
+		p.maxAttempts = cfg.MaxAttempts
+	}
+
+	codes := cfg.StatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		p.statusCodes[code] = true
+	}
+
+	if cfg.InitialBackoff != "" {
+		d, err := time.ParseDuration(cfg.InitialBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid initialBackoff '%s': %w", cfg.InitialBackoff, err)
+		}
+		p.initialBackoff = d
+	}
+	if cfg.MaxBackoff != "" {
+		d, err := time.ParseDuration(cfg.MaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxBackoff '%s': %w", cfg.MaxBackoff, err)
+		}
+		p.maxBackoff = d
+	}
+	if cfg.Multiplier > 0 {
+		p.multiplier = cfg.Multiplier
+	}
+	if cfg.Jitter != nil {
+		p.jitter = *cfg.Jitter
+	}
+	if cfg.RespectRetryAfter != nil {
+		p.respectRetryAfter = *cfg.RespectRetryAfter
+	}
+
+	return p, nil
+}
+
+// resolveRetryPolicy returns the policy for a request: the request-level config
+// wins over the global one. Returns nil when retries are not configured.
+func resolveRetryPolicy(requestCfg *RetryConfig, globalCfg *RetryConfig) (*retryPolicy, error) {
+	if requestCfg != nil {
+		return newRetryPolicy(requestCfg)
+	}
+	return newRetryPolicy(globalCfg)
+}
+
+// isRetryableStatus reports whether the status code should trigger a retry
+func (p *retryPolicy) isRetryableStatus(statusCode int) bool {
+	return p.statusCodes[statusCode]
+}
+
+// delay computes the wait before the next attempt. attempt is the number of the
+// attempt that just failed (1-based). resp may be nil for transport errors.
+func (p *retryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if p.respectRetryAfter && resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(d, p.maxBackoff)
+		}
+	}
+
+	backoff := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.jitter > 0 {
+		backoff += backoff * p.jitter * (2*rand.Float64() - 1)
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	if backoff > float64(p.maxBackoff) {
+		return p.maxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP date (RFC 9110 section 10.2.3).
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// doWithRetry executes the request, retrying transport errors and retryable
+// status codes according to the policy. The request body is rebuilt through
+// req.GetBody between attempts. When all attempts are exhausted the last
+// response (or error) is returned unchanged so the caller can handle it.
+func (c *ApiCrawler) doWithRetry(ctx context.Context, req *http.Request, policy *retryPolicy, pageID string, step Step) (*http.Response, error) {
+	if policy == nil {
+		return c.client.Do(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("error rebuilding request body for retry: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := c.client.Do(req)
+
+		var reason string
+		statusCode := 0
+		if err != nil {
+			// Never retry a cancelled crawl
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			reason = err.Error()
+		} else if policy.isRetryableStatus(resp.StatusCode) {
+			statusCode = resp.StatusCode
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+		} else {
+			return resp, nil
+		}
+
+		if attempt >= policy.maxAttempts {
+			return resp, err
+		}
+
+		wait := policy.delay(attempt, resp)
+		if resp != nil {
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		c.logger.Warning("[Request] %s %s failed (%s), retrying in %s (attempt %d/%d)",
+			req.Method, req.URL.String(), reason, wait, attempt+1, policy.maxAttempts)
+
+		c.profiler.EmitRequestRetry(pageID, step, RequestRetryData{
+			Attempt:     attempt,
+			MaxAttempts: policy.maxAttempts,
+			StatusCode:  statusCode,
+			Reason:      reason,
+			DelayMs:     wait.Milliseconds(),
+		})
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("5", now)
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, ok = parseRetryAfter("Mon, 01 Jan 2024 12:00:10 GMT", now)
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Second, d)
+
+	d, ok = parseRetryAfter("Mon, 01 Jan 2024 11:00:00 GMT", now)
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(0), d, "dates in the past mean retry immediately")
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("-1", now)
+	assert.False(t, ok)
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	p, err := newRetryPolicy(&RetryConfig{})
+	require.Nil(t, err)
+	assert.Equal(t, 3, p.maxAttempts)
+	assert.True(t, p.isRetryableStatus(503))
+	assert.True(t, p.isRetryableStatus(429))
+	assert.False(t, p.isRetryableStatus(500))
+	assert.False(t, p.isRetryableStatus(404))
+	assert.True(t, p.respectRetryAfter)
+
+	p, err = newRetryPolicy(nil)
+	require.Nil(t, err)
+	assert.Nil(t, p, "no config means no retries")
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	jitter := 0.0
+	p, err := newRetryPolicy(&RetryConfig{
+		InitialBackoff: "100ms",
+		MaxBackoff:     "350ms",
+		Multiplier:     2,
+		Jitter:         &jitter,
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, 100*time.Millisecond, p.delay(1, nil))
+	assert.Equal(t, 200*time.Millisecond, p.delay(2, nil))
+	assert.Equal(t, 350*time.Millisecond, p.delay(3, nil), "delay is capped at maxBackoff")
+
+	// Retry-After wins over the computed backoff, capped at maxBackoff
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"0"}}}
+	assert.Equal(t, time.Duration(0), p.delay(2, resp))
+	resp = &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	assert.Equal(t, 350*time.Millisecond, p.delay(1, resp))
+
+	ignore := false
+	p, err = newRetryPolicy(&RetryConfig{InitialBackoff: "100ms", Jitter: &jitter, RespectRetryAfter: &ignore})
+	require.Nil(t, err)
+	assert.Equal(t, 100*time.Millisecond, p.delay(1, resp))
+}
+
+func TestRetryPolicyJitterBounds(t *testing.T) {
+	jitter := 0.5
+	p, err := newRetryPolicy(&RetryConfig{InitialBackoff: "100ms", Jitter: &jitter})
+	require.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		d := p.delay(1, nil)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 150*time.Millisecond)
+	}
+}
+
+func TestRequestRetryOnStatus(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      retry:
+        maxAttempts: 3
+        initialBackoff: 1ms
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1},
+		},
+	})
+
+	// First two attempts fail with 503, the third succeeds
+	var calls int32
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			resp.StatusCode = http.StatusServiceUnavailable
+			resp.Header.Set("Retry-After", "0")
+			resp.Body = io.NopCloser(bytes.NewBufferString(`<html>unavailable</html>`))
+		}
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	profiler := craw.EnableProfiler()
+	var retryEvents []StepProfilerData
+	done := make(chan struct{})
+	go func() {
+		for event := range profiler {
+			if event.Type == EVENT_REQUEST_RETRY {
+				retryEvents = append(retryEvents, event)
+			}
+		}
+		close(done)
+	}()
+
+	err = craw.Run(context.TODO(), nil)
+	close(profiler)
+	<-done
+	require.Nil(t, err)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Len(t, craw.GetData().([]interface{}), 1)
+
+	require.Len(t, retryEvents, 2)
+	assert.Equal(t, 1, retryEvents[0].Data["attempt"])
+	assert.Equal(t, http.StatusServiceUnavailable, retryEvents[0].Data["statusCode"])
+	assert.Equal(t, 2, retryEvents[1].Data["attempt"])
+}
+
+func TestRequestRetryGlobalDefault(t *testing.T) {
+	configContent := `
+rootContext: []
+retry:
+  maxAttempts: 2
+  initialBackoff: 1ms
+  statusCodes: [500]
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1},
+		},
+	})
+
+	var calls int32
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			resp.StatusCode = http.StatusInternalServerError
+		}
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRequestRetryExhausted(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: POST
+      headers:
+        Content-Type: application/json
+      body:
+        query: all
+      retry:
+        maxAttempts: 3
+        initialBackoff: 1ms
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{},
+	})
+
+	// Every attempt must carry the full request body
+	var calls int32
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != `{"query":"all"}` {
+			panic("retried request lost its body: " + string(body))
+		}
+		resp.StatusCode = http.StatusBadGateway
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "should stop after maxAttempts")
+
+	// The last failed response is handed to status handling
+	var statusErr *HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+}
+
+func TestRequestRetryCancelledContext(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      retry:
+        maxAttempts: 5
+        initialBackoff: 10s
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		resp.StatusCode = http.StatusTooManyRequests
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = craw.Run(ctx, nil)
+	require.NotNil(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second, "backoff sleep must be interrupted by cancellation")
+}
+
+func TestValidateRetry(t *testing.T) {
+	jitter := 2.0
+	errs := validateRetry(RetryConfig{
+		MaxAttempts:    -1,
+		StatusCodes:    []int{503, 42},
+		InitialBackoff: "fast",
+		Jitter:         &jitter,
+	}, "retry")
+	require.Len(t, errs, 4)
+	assert.Equal(t, "retry.maxAttempts", errs[0].Location)
+	assert.Equal(t, "retry.statusCodes[1]", errs[1].Location)
+	assert.Equal(t, "retry.initialBackoff", errs[2].Location)
+	assert.Equal(t, "retry.jitter", errs[3].Location)
+}
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// ErrStopStream can be returned by a RunEach callback to stop the crawl
+// without an error
+var ErrStopStream = errors.New("stop stream")
+
+// streamItem is an entity streamed into an output during RunEach
+type streamItem struct {
+	output string
+	entity any
+}
+
+// send pushes an entity to an output ("" is the data stream). It gives up
+// when ctx is cancelled, so a consumer that stopped reading cannot block the crawl.
+func (c *ApiCrawler) send(ctx context.Context, output string, entity any) error {
+	if c.sink != nil {
+		select {
+		case c.sink <- streamItem{output: output, entity: entity}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case c.GetOutputStream(output) <- entity:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunEach runs a stream mode crawl and calls fn with every streamed entity and
+// the name of its output ("" for the data stream), in the order they are
+// streamed. fn is called on the goroutine of RunEach, one entity at a time.
+//
+// The crawler owns the streams: GetDataStream and GetOutputStream are not used
+// and need no consumer. When fn returns an error, the crawl is cancelled and
+// RunEach returns that error, or nil for ErrStopStream. Otherwise it returns
+// the error of the crawl.
+func (c *ApiCrawler) RunEach(ctx context.Context, vars map[string]any, fn func(output string, entity any) error) error {
+	if !c.Config.Stream {
+		return errors.New("RunEach requires stream: true")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sink := make(chan streamItem)
+	c.sink = sink
+	defer func() { c.sink = nil }()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx, vars)
+		close(sink)
+	}()
+
+	var fnErr error
+	for item := range sink {
+		if fnErr != nil {
+			// The crawl is being cancelled: drop what is still in flight
+			continue
+		}
+		if err := fn(item.output, item.entity); err != nil {
+			fnErr = err
+			cancel()
+		}
+	}
+	runErr := <-done
+
+	if errors.Is(fnErr, ErrStopStream) {
+		return nil
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	return runErr
+}
+
+// RunStream runs a stream mode crawl as an iterator over the streamed
+// entities of every output. An error of the crawl is yielded last, with a nil
+// entity. Breaking out of the loop cancels the crawl.
+//
+//	for entity, err := range crawler.RunStream(ctx, nil) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *ApiCrawler) RunStream(ctx context.Context, vars map[string]any) iter.Seq2[any, error] {
+	return func(yield func(any, error) bool) {
+		err := c.RunEach(ctx, vars, func(_ string, entity any) error {
+			if !yield(entity, nil) {
+				return ErrStopStream
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
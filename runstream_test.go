@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const runStreamItemsConfig = `
+rootContext: []
+stream: true
+steps:
+  - type: forValues
+    values: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]
+    as: page
+    steps:
+      - type: request
+        request:
+          url: https://api.example.com/items?page={{ .page }}
+          method: GET
+        noopMerge: true
+        emit: '.[]'
+`
+
+func TestRunStream(t *testing.T) {
+	craw := newTestCrawler(t, runStreamItemsConfig, jsonClient(func(req *http.Request) string {
+		page := req.URL.Query().Get("page")
+		return fmt.Sprintf(`["%s-a", "%s-b"]`, page, page)
+	}))
+
+	var received []any
+	for entity, err := range craw.RunStream(context.TODO(), nil) {
+		require.Nil(t, err)
+		received = append(received, entity)
+	}
+
+	require.Len(t, received, 20)
+	assert.Equal(t, "1-a", received[0])
+	assert.Equal(t, "10-b", received[19])
+}
+
+func TestRunStreamBreak(t *testing.T) {
+	var requests atomic.Int32
+	craw := newTestCrawler(t, runStreamItemsConfig, jsonClient(func(req *http.Request) string {
+		requests.Add(1)
+		return `["a", "b"]`
+	}))
+
+	var received []any
+	for entity, err := range craw.RunStream(context.TODO(), nil) {
+		require.Nil(t, err)
+		received = append(received, entity)
+		break
+	}
+
+	assert.Equal(t, []any{"a"}, received)
+	assert.Less(t, requests.Load(), int32(10), "breaking out of the loop stops the crawl")
+
+	// Nobody reads the streams of the crawler, so a stuck send would hang here
+	var count int
+	for _, err := range craw.RunStream(context.TODO(), nil) {
+		require.Nil(t, err)
+		count++
+	}
+	assert.Equal(t, 20, count, "the crawler can run again after a break")
+}
+
+func TestRunStreamError(t *testing.T) {
+	craw := newTestCrawler(t, runStreamItemsConfig, clientFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("page") == "3" {
+			return nil, errors.New("connection reset")
+		}
+		return jsonResponse(req, `["a"]`), nil
+	}))
+
+	var received []any
+	var errs []error
+	for entity, err := range craw.RunStream(context.TODO(), nil) {
+		if err != nil {
+			errs = append(errs, err)
+			assert.Nil(t, entity)
+			continue
+		}
+		received = append(received, entity)
+	}
+
+	assert.Len(t, received, 2, "entities streamed before the error are yielded")
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "connection reset")
+}
+
+func TestRunEach(t *testing.T) {
+	configContent := `
+rootContext: []
+stream: true
+outputs: [stations]
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/stations
+      method: GET
+    emit: '.[] | .id'
+    output: stations
+`
+	craw := newTestCrawler(t, configContent, jsonClient(func(req *http.Request) string {
+		return `[{"id": "s1"}, {"id": "s2"}]`
+	}))
+
+	received := map[string][]any{}
+	err := craw.RunEach(context.TODO(), nil, func(output string, entity any) error {
+		received[output] = append(received[output], entity)
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []any{"s1", "s2"}, received["stations"])
+	assert.Empty(t, received[""], "the root context is not streamed once a step emits")
+
+	t.Run("callback error", func(t *testing.T) {
+		errStop := errors.New("full")
+		var calls int
+		err := craw.RunEach(context.TODO(), nil, func(output string, entity any) error {
+			calls++
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, calls, "fn is not called after it failed")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		err := craw.RunEach(context.TODO(), nil, func(output string, entity any) error {
+			return ErrStopStream
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := craw.RunEach(ctx, nil, func(output string, entity any) error {
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRunEachRequiresStream(t *testing.T) {
+	craw := newTestCrawler(t, `
+rootContext: []
+steps:
+  - type: request
+    request:
+      url: https://api.example.com/items
+      method: GET
+`, jsonClient(func(req *http.Request) string {
+		return `[]`
+	}))
+
+	err := craw.RunEach(context.TODO(), nil, func(output string, entity any) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "stream: true")
+
+	for _, err := range craw.RunStream(context.TODO(), nil) {
+		assert.ErrorContains(t, err, "stream: true")
+	}
+}
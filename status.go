@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Actions that can be configured for a response status in RequestConfig.OnStatus
+const (
+	StatusActionFail  = "fail"  // abort the step with an HTTPStatusError
+	StatusActionSkip  = "skip"  // discard the response and end the request step; the enclosing iteration goes on
+	StatusActionEmpty = "empty" // treat the body as null and stop pagination
+
+	// statusActionAccept is the implicit action for expected statuses
+	statusActionAccept = "accept"
+)
+
+// maxStatusBodyExcerpt limits how much of an unexpected response body is kept in errors
+const maxStatusBodyExcerpt = 512
+
+// HTTPStatusError is returned when a request step receives a status it is not
+// configured to accept.
+type HTTPStatusError struct {
+	StepPath    string
+	URL         string
+	StatusCode  int
+	BodyExcerpt string
+}
+
+func (e *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("%s: unexpected HTTP status %d from %s", e.StepPath, e.StatusCode, e.URL)
+	if e.BodyExcerpt != "" {
+		msg += ": " + e.BodyExcerpt
+	}
+	return msg
+}
+
+// newHTTPStatusError builds an HTTPStatusError, reading a bounded excerpt of the response body
+func newHTTPStatusError(stepPath string, url string, resp *http.Response) *HTTPStatusError {
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxStatusBodyExcerpt+1))
+	body := strings.TrimSpace(string(excerpt))
+	if len(excerpt) > maxStatusBodyExcerpt {
+		body = strings.TrimSpace(string(excerpt[:maxStatusBodyExcerpt])) + "..."
+	}
+	return &HTTPStatusError{
+		StepPath:    stepPath,
+		URL:         url,
+		StatusCode:  resp.StatusCode,
+		BodyExcerpt: body,
+	}
+}
+
+// resolveStatusAction determines what to do with a response status.
+// Priority: exact code in onStatus, status class (e.g. "4xx") in onStatus,
+// expectStatus (or any 2xx when expectStatus is empty), otherwise fail.
+func resolveStatusAction(req *RequestConfig, statusCode int) string {
+	if action, ok := req.OnStatus[strconv.Itoa(statusCode)]; ok {
+		return strings.ToLower(action)
+	}
+	// Class keys are case-insensitive, like in isValidStatusKey ("4xx" or "4XX")
+	class := statusClass(statusCode)
+	for key, action := range req.OnStatus {
+		if strings.EqualFold(key, class) {
+			return strings.ToLower(action)
+		}
+	}
+
+	if len(req.ExpectStatus) == 0 {
+		if statusCode >= 200 && statusCode < 300 {
+			return statusActionAccept
+		}
+		return StatusActionFail
+	}
+	for _, expected := range req.ExpectStatus {
+		if expected == statusCode {
+			return statusActionAccept
+		}
+	}
+	return StatusActionFail
+}
+
+// statusClass returns the class key for a status code (e.g. 404 -> "4xx")
+func statusClass(statusCode int) string {
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// isValidStatusKey reports whether key is a status code ("404") or class ("4xx")
+func isValidStatusKey(key string) bool {
+	if len(key) != 3 {
+		return false
+	}
+	if strings.HasSuffix(strings.ToLower(key), "xx") {
+		return key[0] >= '1' && key[0] <= '5'
+	}
+	code, err := strconv.Atoi(key)
+	return err == nil && code >= 100 && code <= 599
+}
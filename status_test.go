@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2024 NOI Techpark <digital@noi.bz.it>
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package silky
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	crawler_testing "github.com/noi-techpark/go-silky/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveStatusAction(t *testing.T) {
+	// Defaults: any 2xx is accepted, everything else fails
+	req := &RequestConfig{}
+	assert.Equal(t, statusActionAccept, resolveStatusAction(req, 200))
+	assert.Equal(t, statusActionAccept, resolveStatusAction(req, 204))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 301))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 404))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 500))
+
+	// expectStatus replaces the 2xx default
+	req = &RequestConfig{ExpectStatus: []int{200, 304}}
+	assert.Equal(t, statusActionAccept, resolveStatusAction(req, 304))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 201))
+
+	// Exact codes win over classes
+	req = &RequestConfig{OnStatus: map[string]string{"404": "skip", "4xx": "empty", "5xx": "Fail"}}
+	assert.Equal(t, StatusActionSkip, resolveStatusAction(req, 404))
+	assert.Equal(t, StatusActionEmpty, resolveStatusAction(req, 410))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 503))
+	assert.Equal(t, statusActionAccept, resolveStatusAction(req, 200))
+
+	// Class keys accepted by validation match regardless of case
+	req = &RequestConfig{OnStatus: map[string]string{"4XX": "skip"}}
+	require.True(t, isValidStatusKey("4XX"))
+	assert.Equal(t, StatusActionSkip, resolveStatusAction(req, 404))
+	assert.Equal(t, StatusActionFail, resolveStatusAction(req, 500))
+}
+
+func TestIsValidStatusKey(t *testing.T) {
+	assert.True(t, isValidStatusKey("404"))
+	assert.True(t, isValidStatusKey("4xx"))
+	assert.True(t, isValidStatusKey("5XX"))
+	assert.False(t, isValidStatusKey("6xx"))
+	assert.False(t, isValidStatusKey("99"))
+	assert.False(t, isValidStatusKey("abc"))
+	assert.False(t, isValidStatusKey("600"))
+}
+
+func TestHTTPStatusErrorExcerpt(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 500,
+		Body:       io.NopCloser(bytes.NewBufferString(strings.Repeat("x", 2000))),
+	}
+	err := newHTTPStatusError("steps[0]", "https://api.example.com/items", resp)
+	assert.Equal(t, 500, err.StatusCode)
+	assert.Equal(t, maxStatusBodyExcerpt+3, len(err.BodyExcerpt), "excerpt is truncated with an ellipsis")
+	assert.True(t, strings.HasPrefix(err.Error(), "steps[0]: unexpected HTTP status 500 from https://api.example.com/items"))
+}
+
+func TestRequestStatusFailByDefault(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		resp.StatusCode = http.StatusInternalServerError
+		resp.Body = io.NopCloser(bytes.NewBufferString("<html>Internal Server Error</html>"))
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+
+	var statusErr *HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "steps[0]", statusErr.StepPath)
+	assert.Equal(t, "https://api.example.com/items", statusErr.URL)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+	assert.Equal(t, "<html>Internal Server Error</html>", statusErr.BodyExcerpt)
+}
+
+func TestRequestStatusSkipInForEach(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch list"
+    request:
+      url: https://api.example.com/items
+      method: GET
+    steps:
+      - type: forEach
+        path: .
+        as: item
+        steps:
+          - type: request
+            name: "Fetch details"
+            request:
+              url: https://api.example.com/items/{{ .item.id }}
+              method: GET
+              onStatus:
+                404: skip
+            mergeOn: .details = $res
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	// Details for item 2 respond with 404
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{
+			map[string]interface{}{"id": 1},
+			map[string]interface{}{"id": 2},
+		},
+		"https://api.example.com/items/1": map[string]interface{}{"name": "one"},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		if req.URL.Path == "/items/2" {
+			resp.StatusCode = http.StatusNotFound
+			resp.Body = io.NopCloser(bytes.NewBufferString(`{"error": "not found"}`))
+		}
+	}
+	// The URL must match an expectation to reach InterceptFunc
+	mockTransport.Expectations = append(mockTransport.Expectations, crawler_testing.MockExpectation{
+		Request:  crawler_testing.MockRequest{URL: "https://api.example.com/items/2"},
+		Response: crawler_testing.MockResponse{StatusCode: http.StatusOK, BodyJSON: map[string]interface{}{}},
+	})
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+
+	data := craw.GetData().([]interface{})
+	require.Len(t, data, 2)
+	assert.Equal(t, map[string]interface{}{"name": "one"}, data[0].(map[string]interface{})["details"])
+	_, hasDetails := data[1].(map[string]interface{})["details"]
+	assert.False(t, hasDetails, "skipped item keeps its original data")
+}
+
+func TestRequestStatusEmpty(t *testing.T) {
+	configContent := `
+rootContext: {}
+steps:
+  - type: request
+    name: "Fetch optional data"
+    request:
+      url: https://api.example.com/optional
+      method: GET
+      onStatus:
+        4xx: empty
+    resultTransformer: '{optional: (. // "missing")}'
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/optional": map[string]interface{}{},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		resp.StatusCode = http.StatusGone
+		resp.Body = io.NopCloser(bytes.NewBufferString("gone"))
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"optional": "missing"}, craw.GetData())
+}
+
+func TestRequestStatusExpectStatus(t *testing.T) {
+	configContent := `
+rootContext: []
+steps:
+  - type: request
+    name: "Fetch items"
+    request:
+      url: https://api.example.com/items
+      method: GET
+      expectStatus: [200]
+`
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.Nil(t, err)
+
+	mockTransport := crawler_testing.NewMockRoundTripperWithResponse(map[string]interface{}{
+		"https://api.example.com/items": []interface{}{},
+	})
+	mockTransport.InterceptFunc = func(req *http.Request, resp *http.Response) {
+		resp.StatusCode = http.StatusAccepted
+	}
+
+	craw, _, err := NewApiCrawler(configPath)
+	require.Nil(t, err)
+	craw.SetClient(&http.Client{Transport: mockTransport})
+
+	err = craw.Run(context.TODO(), nil)
+	var statusErr *HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusAccepted, statusErr.StatusCode)
+}
+
+func TestValidateStatusHandling(t *testing.T) {
+	errs := validateRequest(RequestConfig{
+		URL:          "https://api.example.com",
+		Method:       "GET",
+		ExpectStatus: []int{200, 1000},
+		OnStatus:     map[string]string{"4xx": "ignore"},
+	}, "steps[0].request")
+	require.Len(t, errs, 2)
+	assert.Equal(t, "steps[0].request.expectStatus[1]", errs[0].Location)
+	assert.Equal(t, "steps[0].request.onStatus.4xx", errs[1].Location)
+}
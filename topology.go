@@ -26,6 +26,7 @@ type StepNode struct {
 	// Streaming characteristics
 	MergesToRoot bool   // Merge target is root or depth <= 1 (triggers streaming)
 	MergeTarget  string // Context name that this step merges to
+	Emits        bool   // emit step or emit option: pushes values to the stream at any depth
 }
 
 // StepTopology holds the complete step execution topology.
@@ -41,6 +42,7 @@ type StepTopology struct {
 	MaxDepth     int
 	TotalSteps   int
 	ParallelSteps int
+	EmitSteps    int // Steps that emit; when > 0 only they stream
 }
 
 // GetNode retrieves a step node by its path.
@@ -52,12 +54,14 @@ func (t *StepTopology) GetNode(path string) *StepNode {
 }
 
 // IsStreamingPoint returns true if this step should trigger streaming
-// when it completes (merges to root at depth <= 1).
+// when it completes (merges to root at depth <= 1) or emits values.
+// In a config with emitting steps, the root merges are not streamed:
+// see StepTopology.GetStreamingPoints.
 func (n *StepNode) IsStreamingPoint() bool {
 	if n == nil {
 		return false
 	}
-	return n.MergesToRoot && n.Depth <= 1
+	return n.Emits || (n.MergesToRoot && n.Depth <= 1)
 }
 
 // GetMergeTargetNode returns the node that this step merges to.
@@ -104,6 +108,7 @@ func buildStepNode(step *Step, parent *StepNode, path string, depth int, topolog
 		IsForEach:  step.Type == "forEach" || step.Type == "forValues",
 		IsParallel: step.Parallelism != nil,
 		HasMerge:   hasMergeRule(step),
+		Emits:      step.Type == "emit" || step.Emit != "",
 	}
 
 	// Determine merge target
@@ -117,6 +122,9 @@ func buildStepNode(step *Step, parent *StepNode, path string, depth int, topolog
 	if node.IsParallel {
 		topology.ParallelSteps++
 	}
+	if node.Emits {
+		topology.EmitSteps++
+	}
 
 	// Store in lookup map
 	topology.ByPath[path] = node
@@ -227,6 +235,7 @@ func walkPostOrder(node *StepNode, fn func(*StepNode) bool) bool {
 }
 
 // GetStreamingPoints returns all nodes that should trigger streaming.
+// When some steps emit, only the emitting steps stream.
 func (t *StepTopology) GetStreamingPoints() []*StepNode {
 	if t == nil {
 		return nil
@@ -234,6 +243,9 @@ func (t *StepTopology) GetStreamingPoints() []*StepNode {
 
 	var points []*StepNode
 	t.WalkPreOrder(func(node *StepNode) bool {
+		if t.EmitSteps > 0 && !node.Emits {
+			return true
+		}
 		if node.IsStreamingPoint() {
 			points = append(points, node)
 		}
@@ -286,6 +298,9 @@ func (n *StepNode) String() string {
 	if n.MergesToRoot {
 		flags += "R"
 	}
+	if n.Emits {
+		flags += "E"
+	}
 
 	name := ""
 	if n.StepRef != nil && n.StepRef.Name != "" {
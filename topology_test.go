@@ -374,6 +374,46 @@ func TestTopology_GetStreamingPoints(t *testing.T) {
 	assert.Contains(t, names, "deep-foreach")
 }
 
+// TestTopology_EmitStreamingPoints tests that emitting steps replace the root merges as streaming points
+func TestTopology_EmitStreamingPoints(t *testing.T) {
+	cfg := Config{
+		RootContext: []interface{}{},
+		Stream:      true,
+		Steps: []Step{
+			{
+				Type:    "request",
+				Name:    "list",
+				MergeOn: ". = $res",
+				Steps: []Step{
+					{
+						Type: "forEach",
+						Name: "items",
+						Path: ".",
+						As:   "item",
+						Steps: []Step{
+							{Type: "request", Name: "details", Emit: "."},
+							{Type: "emit", Name: "tags", Emit: ".tags[]"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	topology := BuildTopology(cfg)
+	assert.Equal(t, 2, topology.EmitSteps)
+
+	var names []string
+	for _, p := range topology.GetStreamingPoints() {
+		names = append(names, p.StepRef.Name)
+	}
+	assert.Equal(t, []string{"details", "tags"}, names)
+
+	details := topology.GetNode("steps[0].steps[0].steps[0]")
+	assert.True(t, details.IsStreamingPoint(), "emitting steps stream at any depth")
+	assert.Contains(t, details.String(), "E")
+}
+
 // TestTopology_GetParallelBranches tests finding parallel branches
 func TestTopology_GetParallelBranches(t *testing.T) {
 	cfg := Config{
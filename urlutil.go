@@ -123,3 +123,133 @@ func shouldPercentEncode(c byte) bool {
 	}
 	return true
 }
+
+// LinkHeaderTarget returns the target of the first link with the given relation
+// type in RFC 8288 Link header values, e.g.
+//
+//	Link: <https://api.example.com/items?page=2>; rel="next", <...>; rel="last"
+//
+// Relation types are matched case-insensitively and a link may declare several
+// ("rel=\"next last\""). Relative targets are resolved against base when it is
+// not nil. Returns false if no link has the relation.
+func LinkHeaderTarget(values []string, rel string, base *url.URL) (string, bool) {
+	for _, value := range values {
+		for _, link := range parseLinkHeader(value) {
+			for _, r := range strings.Fields(link.params["rel"]) {
+				if !strings.EqualFold(r, rel) {
+					continue
+				}
+				if base == nil {
+					return link.target, true
+				}
+				ref, err := url.Parse(link.target)
+				if err != nil {
+					return link.target, true
+				}
+				return base.ResolveReference(ref).String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// linkValue is a single link of a Link header
+type linkValue struct {
+	target string
+	params map[string]string // lower-cased names; the first occurrence wins
+}
+
+// parseLinkHeader parses one Link header value into its links. Malformed links
+// are skipped. Commas and semicolons inside the target or quoted parameter
+// values do not split links.
+func parseLinkHeader(value string) []linkValue {
+	var links []linkValue
+	s := value
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return links
+		}
+		if s[0] != '<' {
+			// Skip a malformed link up to the next separator
+			s = skipLinkValue(s)
+			continue
+		}
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return links
+		}
+		link := linkValue{target: strings.TrimSpace(s[1:end]), params: map[string]string{}}
+		s = s[end+1:]
+
+		// Parameters: *( OWS ";" OWS link-param )
+		for {
+			s = strings.TrimLeft(s, " \t")
+			if s == "" || s[0] != ';' {
+				break
+			}
+			s = strings.TrimLeft(s[1:], " \t")
+			nameEnd := strings.IndexAny(s, "=;, \t")
+			if nameEnd < 0 {
+				nameEnd = len(s)
+			}
+			name := strings.ToLower(s[:nameEnd])
+			s = strings.TrimLeft(s[nameEnd:], " \t")
+
+			var val string
+			if strings.HasPrefix(s, "=") {
+				s = strings.TrimLeft(s[1:], " \t")
+				val, s = readLinkParamValue(s)
+			}
+			if _, exists := link.params[name]; name != "" && !exists {
+				link.params[name] = val
+			}
+		}
+		links = append(links, link)
+		s = skipLinkValue(s)
+	}
+}
+
+// readLinkParamValue reads a token or quoted-string parameter value and
+// returns it with the rest of the input
+func readLinkParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, ";, \t")
+		if end < 0 {
+			return s, ""
+		}
+		return s[:end], s[end:]
+	}
+	var buf strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				buf.WriteByte(s[i])
+			}
+		case '"':
+			return buf.String(), s[i+1:]
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String(), ""
+}
+
+// skipLinkValue skips to the comma separating the next link, ignoring commas
+// inside quoted strings
+func skipLinkValue(s string) string {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ',' && !quoted:
+			return s[i+1:]
+		}
+	}
+	return ""
+}
@@ -179,3 +179,39 @@ func TestSetQueryParamsPreservesHashInExistingQuery(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, parsed.RawQuery, "token=abc%23def+xyz")
 }
+
+func TestLinkHeaderTarget(t *testing.T) {
+	base, err := url.Parse("https://api.example.com/v1/items?page=2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		values   []string
+		rel      string
+		base     *url.URL
+		expected string
+		found    bool
+	}{
+		{"single link", []string{`<https://api.example.com/items?page=3>; rel="next"`}, "next", nil, "https://api.example.com/items?page=3", true},
+		{"unquoted rel", []string{`<https://api.example.com/items?page=3>; rel=next`}, "next", nil, "https://api.example.com/items?page=3", true},
+		{"rel is case-insensitive", []string{`<https://api.example.com/items?page=3>; REL="Next"`}, "next", nil, "https://api.example.com/items?page=3", true},
+		{"picks the requested relation", []string{`<https://a.example.com/1>; rel="prev", <https://a.example.com/3>; rel="next"`}, "next", nil, "https://a.example.com/3", true},
+		{"several relations", []string{`<https://a.example.com/9>; rel="next last"`}, "last", nil, "https://a.example.com/9", true},
+		{"several header values", []string{`<https://a.example.com/1>; rel="first"`, `<https://a.example.com/3>; rel="next"`}, "next", nil, "https://a.example.com/3", true},
+		{"quoted commas and semicolons", []string{`<https://a.example.com/1>; title="a, b; rel=next"; rel="prev", <https://a.example.com/3>; rel="next"`}, "next", nil, "https://a.example.com/3", true},
+		{"comma in target", []string{`<https://a.example.com/items?ids=1,2>; rel="next"`}, "next", nil, "https://a.example.com/items?ids=1,2", true},
+		{"relative path", []string{`</v1/items?page=3>; rel="next"`}, "next", base, "https://api.example.com/v1/items?page=3", true},
+		{"relative query", []string{`<?page=3>; rel="next"`}, "next", base, "https://api.example.com/v1/items?page=3", true},
+		{"malformed link skipped", []string{`https://a.example.com/1; rel="next", <https://a.example.com/3>; rel="next"`}, "next", nil, "https://a.example.com/3", true},
+		{"missing relation", []string{`<https://a.example.com/1>; rel="prev"`}, "next", nil, "", false},
+		{"no header", nil, "next", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, found := LinkHeaderTarget(tt.values, tt.rel, tt.base)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, target)
+		})
+	}
+}
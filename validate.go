@@ -6,7 +6,10 @@ package silky
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 )
 
 type ValidationError struct {
@@ -48,6 +51,32 @@ func ValidateConfig(cfg Config) []ValidationError {
 		errs = append(errs, validateAuth(*cfg.Authentication, "auth")...)
 	}
 
+	// validate global retry policy if present
+	if cfg.Retry != nil {
+		errs = append(errs, validateRetry(*cfg.Retry, "retry")...)
+	}
+
+	// validate checkpoint settings if present
+	if cfg.Checkpoint != nil && cfg.Checkpoint.Interval < 0 {
+		errs = append(errs, ValidationError{"checkpoint.interval must be >= 0", "checkpoint.interval"})
+	}
+
+	// validate global rate limits if present
+	if cfg.RateLimits != nil {
+		errs = append(errs, validateRateLimits(*cfg.RateLimits, "rateLimits")...)
+	}
+
+	// validate incremental watermarks if present
+	if cfg.Incremental != nil {
+		errs = append(errs, validateIncremental(*cfg.Incremental, "incremental")...)
+	}
+
+	errs = append(errs, validateOutputs(cfg)...)
+
+	if !isValidNumberMode(cfg.NumberMode) {
+		errs = append(errs, ValidationError{fmt.Sprintf("numberMode must be one of [exact, float], got '%s'", cfg.NumberMode), "numberMode"})
+	}
+
 	// headers optional, but if present must be map[string]string (assumed unmarshalled correctly)
 
 	// steps required and non-empty
@@ -57,6 +86,124 @@ func ValidateConfig(cfg Config) []ValidationError {
 		for i, step := range cfg.Steps {
 			errs = append(errs, validateStep(step, fmt.Sprintf("steps[%d]", i))...)
 		}
+		if !cfg.Stream {
+			errs = append(errs, validateEmitWithoutStream(cfg.Steps, "steps")...)
+		}
+		errs = append(errs, validateStepOutputs(cfg.Steps, "steps", cfg.Outputs, BuildTopology(cfg))...)
+	}
+
+	return errs
+}
+
+// outputNamePattern restricts output names to characters that are safe in file names
+var outputNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateOutputs checks the named outputs of a config
+func validateOutputs(cfg Config) []ValidationError {
+	var errs []ValidationError
+	if len(cfg.Outputs) > 0 && !cfg.Stream {
+		errs = append(errs, ValidationError{"outputs requires stream: true", "outputs"})
+	}
+	seen := make(map[string]bool, len(cfg.Outputs))
+	for i, name := range cfg.Outputs {
+		loc := fmt.Sprintf("outputs[%d]", i)
+		switch {
+		case !outputNamePattern.MatchString(name):
+			errs = append(errs, ValidationError{fmt.Sprintf("output name '%s' may only contain letters, digits, '_' and '-'", name), loc})
+		case name == "default":
+			errs = append(errs, ValidationError{"output name 'default' is reserved for the data stream", loc})
+		case seen[name]:
+			errs = append(errs, ValidationError{fmt.Sprintf("duplicate output '%s'", name), loc})
+		}
+		seen[name] = true
+	}
+	return errs
+}
+
+// validateStepOutputs reports the steps that stream into an undeclared output,
+// and those with an output that never send anything to it. Besides emitting
+// steps, only top-level streaming points send data, and only when no step emits:
+// data merged to root by nested steps is streamed by their top-level step.
+func validateStepOutputs(steps []Step, location string, outputs []string, topology *StepTopology) []ValidationError {
+	var errs []ValidationError
+	for i, step := range steps {
+		loc := fmt.Sprintf("%s[%d]", location, i)
+		if step.Output != "" {
+			if !slices.Contains(outputs, step.Output) {
+				errs = append(errs, ValidationError{fmt.Sprintf("output '%s' is not declared in outputs", step.Output), loc + ".output"})
+			}
+			node := topology.GetNode(loc)
+			streams := node.Emits || (topology.EmitSteps == 0 && node.Depth == 0 && node.IsStreamingPoint())
+			if !streams {
+				errs = append(errs, ValidationError{"output requires the step to emit or to be a top-level streaming step", loc + ".output"})
+			}
+		}
+		errs = append(errs, validateStepOutputs(step.Steps, loc+".steps", outputs, topology)...)
+	}
+	return errs
+}
+
+// validateEmitWithoutStream reports the steps that emit values although the
+// config has no data stream to push them to
+func validateEmitWithoutStream(steps []Step, location string) []ValidationError {
+	var errs []ValidationError
+	for i, step := range steps {
+		loc := fmt.Sprintf("%s[%d]", location, i)
+		if step.Emit != "" {
+			errs = append(errs, ValidationError{"emit requires stream: true", loc + ".emit"})
+		}
+		errs = append(errs, validateEmitWithoutStream(step.Steps, loc+".steps")...)
+	}
+	return errs
+}
+
+func validateRateLimits(limits RateLimitsConfig, location string) []ValidationError {
+	var errs []ValidationError
+
+	if limits.MaxInFlight < 0 {
+		errs = append(errs, ValidationError{"rateLimits.maxInFlight must be >= 0", location + ".maxInFlight"})
+	}
+	for i, h := range limits.Hosts {
+		loc := fmt.Sprintf("%s.hosts[%d]", location, i)
+		if h.Match == "" {
+			errs = append(errs, ValidationError{"match is required", loc + ".match"})
+		}
+		if h.RequestsPerSecond < 0 {
+			errs = append(errs, ValidationError{"requestsPerSecond must be >= 0", loc + ".requestsPerSecond"})
+		}
+		if h.Burst < 0 {
+			errs = append(errs, ValidationError{"burst must be >= 0", loc + ".burst"})
+		}
+		if h.MaxConcurrency < 0 {
+			errs = append(errs, ValidationError{"maxConcurrency must be >= 0", loc + ".maxConcurrency"})
+		}
+	}
+
+	return errs
+}
+
+func validateIncremental(inc IncrementalConfig, location string) []ValidationError {
+	var errs []ValidationError
+
+	if len(inc.Watermarks) == 0 {
+		errs = append(errs, ValidationError{"incremental requires at least one watermark", location + ".watermarks"})
+	}
+
+	names := map[string]bool{}
+	for i, wm := range inc.Watermarks {
+		loc := fmt.Sprintf("%s.watermarks[%d]", location, i)
+		if wm.Name == "" {
+			errs = append(errs, ValidationError{"watermark name is required", loc + ".name"})
+		} else if names[wm.Name] {
+			errs = append(errs, ValidationError{fmt.Sprintf("duplicate watermark name '%s'", wm.Name), loc + ".name"})
+		}
+		names[wm.Name] = true
+		if wm.Selector == "" {
+			errs = append(errs, ValidationError{"watermark selector is required", loc + ".selector"})
+		}
+		if !isValidWatermarkAggregate(wm.Aggregate) {
+			errs = append(errs, ValidationError{fmt.Sprintf("invalid aggregate '%s' (must be max, min or last)", wm.Aggregate), loc + ".aggregate"})
+		}
 	}
 
 	return errs
@@ -171,15 +318,48 @@ func validateAuth(auth AuthenticatorConfig, location string) []ValidationError {
 	return errs
 }
 
+func validateParallelism(p ParallelismConfig, location string) []ValidationError {
+	var errs []ValidationError
+
+	if p.ErrorMode != "" && p.ErrorMode != ParallelErrorModeFailFast && p.ErrorMode != ParallelErrorModeCollectErrors {
+		errs = append(errs, ValidationError{fmt.Sprintf("invalid errorMode '%s' (must be failFast or collectErrors)", p.ErrorMode), location + ".errorMode"})
+	}
+
+	return errs
+}
+
 func validateStep(step Step, location string) []ValidationError {
 	var errs []ValidationError
 
 	t := strings.ToLower(step.Type)
-	if t != "foreach" && t != "request" && t != "forvalues" {
-		errs = append(errs, ValidationError{fmt.Sprintf("step.type must be 'foreach', 'forValues', or 'request', got '%s'", step.Type), location + ".type"})
+	if t != "foreach" && t != "request" && t != "forvalues" && t != "emit" {
+		errs = append(errs, ValidationError{fmt.Sprintf("step.type must be 'foreach', 'forValues', 'request' or 'emit', got '%s'", step.Type), location + ".type"})
+		return errs
+	}
+
+	if t == "emit" {
+		// emit rules - only pushes values to the data stream
+		if step.Emit == "" {
+			errs = append(errs, ValidationError{"emit step requires emit", location + ".emit"})
+		}
+		if step.Request != nil || step.Path != "" || step.As != "" || len(step.Values) > 0 || step.Parallelism != nil || step.OnError != "" {
+			errs = append(errs, ValidationError{"emit step only supports name, when and emit", location})
+		}
+		if step.MergeOn != "" || step.MergeWithParentOn != "" || step.MergeWithContext != nil || step.NoopMerge || step.ResultTransformer != "" {
+			errs = append(errs, ValidationError{"emit step does not transform or merge data", location})
+		}
+		if len(step.Steps) > 0 {
+			errs = append(errs, ValidationError{"emit step does not support nested steps", location + ".steps"})
+		}
 		return errs
 	}
 
+	if !isValidOnError(step.OnError) {
+		errs = append(errs, ValidationError{fmt.Sprintf("invalid onError '%s' (must be fail, skip or collect)", step.OnError), location + ".onError"})
+	} else if step.OnError != "" && t == "request" {
+		errs = append(errs, ValidationError{"onError is only supported on forEach and forValues steps (use request.onStatus for requests)", location + ".onError"})
+	}
+
 	if t == "forvalues" {
 		// forValues rules - only accepts literal values, no path
 		if len(step.Values) == 0 {
@@ -195,9 +375,8 @@ func validateStep(step Step, location string) []ValidationError {
 		if step.MergeOn != "" || step.MergeWithParentOn != "" || step.MergeWithContext != nil || step.NoopMerge {
 			errs = append(errs, ValidationError{"forValues step does not support merge options (nested steps handle merging)", location})
 		}
-		// forValues does not support parallelism (yet)
 		if step.Parallelism != nil {
-			errs = append(errs, ValidationError{"forValues step does not support parallelism", location + ".parallelism"})
+			errs = append(errs, validateParallelism(*step.Parallelism, location+".parallelism")...)
 		}
 		// Validate nested steps
 		for i, nested := range step.Steps {
@@ -212,6 +391,9 @@ func validateStep(step Step, location string) []ValidationError {
 		if step.As == "" {
 			errs = append(errs, ValidationError{"forEach step requires as", location + ".as"})
 		}
+		if step.Parallelism != nil {
+			errs = append(errs, validateParallelism(*step.Parallelism, location+".parallelism")...)
+		}
 		// if len(step.Steps) == 0 {
 		// 	errs = append(errs, ValidationError{"foreach step requires nested steps", location + ".steps"})
 		// }
@@ -340,7 +522,30 @@ func validateRequest(req RequestConfig, location string) []ValidationError {
 		errs = append(errs, validateAuth(*req.Authentication, location+".auth")...)
 	}
 
-	if len(req.Pagination.Params) > 0 || len(req.Pagination.StopOn) > 0 {
+	if req.Retry != nil {
+		errs = append(errs, validateRetry(*req.Retry, location+".retry")...)
+	}
+
+	for i, code := range req.ExpectStatus {
+		if code < 100 || code > 599 {
+			errs = append(errs, ValidationError{fmt.Sprintf("request.expectStatus contains invalid status code %d", code), fmt.Sprintf("%s.expectStatus[%d]", location, i)})
+		}
+	}
+	for key, action := range req.OnStatus {
+		if !isValidStatusKey(key) {
+			errs = append(errs, ValidationError{fmt.Sprintf("request.onStatus key must be a status code (e.g. 404) or class (e.g. 4xx), got '%s'", key), location + ".onStatus." + key})
+		}
+		a := strings.ToLower(action)
+		if a != StatusActionFail && a != StatusActionSkip && a != StatusActionEmpty {
+			errs = append(errs, ValidationError{fmt.Sprintf("request.onStatus action must be one of [fail, skip, empty], got '%s'", action), location + ".onStatus." + key})
+		}
+	}
+
+	if !isValidResponseFormat(req.ResponseFormat) {
+		errs = append(errs, ValidationError{fmt.Sprintf("request.responseFormat must be one of [json, xml, csv, ndjson, text, auto], got '%s'", req.ResponseFormat), location + ".responseFormat"})
+	}
+
+	if !req.Pagination.isEmpty() {
 		errs = append(errs, validatePagination(req.Pagination, location+".pagination")...)
 	}
 
@@ -349,12 +554,61 @@ func validateRequest(req RequestConfig, location string) []ValidationError {
 	return errs
 }
 
+func validateRetry(retry RetryConfig, location string) []ValidationError {
+	var errs []ValidationError
+
+	if retry.MaxAttempts < 0 {
+		errs = append(errs, ValidationError{"retry.maxAttempts must be >= 0", location + ".maxAttempts"})
+	}
+	for i, code := range retry.StatusCodes {
+		if code < 100 || code > 599 {
+			errs = append(errs, ValidationError{fmt.Sprintf("retry.statusCodes contains invalid status code %d", code), fmt.Sprintf("%s.statusCodes[%d]", location, i)})
+		}
+	}
+	if retry.InitialBackoff != "" {
+		if d, err := time.ParseDuration(retry.InitialBackoff); err != nil || d < 0 {
+			errs = append(errs, ValidationError{fmt.Sprintf("retry.initialBackoff must be a non-negative duration (e.g. 500ms), got '%s'", retry.InitialBackoff), location + ".initialBackoff"})
+		}
+	}
+	if retry.MaxBackoff != "" {
+		if d, err := time.ParseDuration(retry.MaxBackoff); err != nil || d < 0 {
+			errs = append(errs, ValidationError{fmt.Sprintf("retry.maxBackoff must be a non-negative duration (e.g. 30s), got '%s'", retry.MaxBackoff), location + ".maxBackoff"})
+		}
+	}
+	if retry.Multiplier < 0 {
+		errs = append(errs, ValidationError{"retry.multiplier must be >= 0", location + ".multiplier"})
+	}
+	if retry.Jitter != nil && (*retry.Jitter < 0 || *retry.Jitter > 1) {
+		errs = append(errs, ValidationError{"retry.jitter must be between 0 and 1", location + ".jitter"})
+	}
+
+	return errs
+}
+
 func validatePagination(p Pagination, location string) []ValidationError {
 	var errs []ValidationError
 
-	// Either params or nextPageUrlSelector must be provided
-	if len(p.Params) == 0 && p.NextPageUrlSelector == "" {
-		errs = append(errs, ValidationError{"pagination must have either params or nextPageUrlSelector", location})
+	// A preset is validated together with the fields it fills in
+	if p.Preset != "" {
+		resolved, err := p.withPreset()
+		if err != nil {
+			return []ValidationError{{fmt.Sprintf("pagination.preset must be one of %v, got '%s'", PaginationPresetNames(), p.Preset), location + ".preset"}}
+		}
+		p = resolved
+	}
+
+	// Either params, nextPageUrlSelector or window must be provided
+	if len(p.Params) == 0 && p.NextPageUrlSelector == "" && p.Window == nil {
+		errs = append(errs, ValidationError{"pagination must have either params, nextPageUrlSelector or window", location})
+	}
+	if p.NextPageUrlSelector != "" {
+		sourceType, sourcePath, _ := strings.Cut(p.NextPageUrlSelector, ":")
+		switch {
+		case sourceType != "body" && sourceType != "header" && sourceType != "link":
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.nextPageUrlSelector must start with 'body:', 'header:' or 'link:', got '%s'", p.NextPageUrlSelector), location + ".nextPageUrlSelector"})
+		case sourcePath == "":
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.nextPageUrlSelector '%s' is missing the expression, header name or link relation after ':'", p.NextPageUrlSelector), location + ".nextPageUrlSelector"})
+		}
 	}
 
 	// If Params is provided, validate each
@@ -362,14 +616,126 @@ func validatePagination(p Pagination, location string) []ValidationError {
 		errs = append(errs, validatePaginationParam(param, fmt.Sprintf("%s.params[%d]", location, i))...)
 	}
 
-	// StopOn must always be non-empty
-	if len(p.StopOn) == 0 && p.NextPageUrlSelector == "" {
-		errs = append(errs, ValidationError{"pagination.stopOn must be a non-empty array if not using 'nextPageUrlSelector'", location + ".stopOn"})
+	// StopOn must be non-empty unless the last page is known otherwise
+	if len(p.StopOn) == 0 && p.NextPageUrlSelector == "" && p.Total == nil && p.Window == nil {
+		errs = append(errs, ValidationError{"pagination.stopOn must be a non-empty array if not using 'nextPageUrlSelector', 'total' or 'window'", location + ".stopOn"})
 	}
 	for i, stop := range p.StopOn {
 		errs = append(errs, validatePaginationStop(stop, fmt.Sprintf("%s.stopOn[%d]", location, i))...)
 	}
 
+	if p.MaxPages < 0 {
+		errs = append(errs, ValidationError{"pagination.maxPages must not be negative", location + ".maxPages"})
+	}
+	if p.MaxDuration != "" {
+		if d, err := time.ParseDuration(p.MaxDuration); err != nil || d <= 0 {
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.maxDuration must be a positive duration (e.g. '10m'), got '%s'", p.MaxDuration), location + ".maxDuration"})
+		}
+	}
+	if !isValidOnLoop(p.OnLoop) {
+		errs = append(errs, ValidationError{fmt.Sprintf("invalid onLoop '%s' (must be error, stop or ignore)", p.OnLoop), location + ".onLoop"})
+	}
+
+	if p.Total != nil {
+		errs = append(errs, validatePaginationTotal(p, location+".total")...)
+	}
+	if p.Window != nil {
+		errs = append(errs, validatePaginationWindow(p, location+".window")...)
+	}
+	if p.Parallelism != nil {
+		if p.Total == nil {
+			errs = append(errs, ValidationError{"pagination.parallelism requires pagination.total", location + ".parallelism"})
+		}
+		errs = append(errs, validateParallelism(*p.Parallelism, location+".parallelism")...)
+	}
+
+	return errs
+}
+
+// validatePaginationWindow checks the window bounds and the params they are bound to
+func validatePaginationWindow(p Pagination, location string) []ValidationError {
+	var errs []ValidationError
+
+	w := p.Window
+	format := w.Format
+	if format == "" {
+		format = time.RFC3339
+	}
+	if w.Start == "" {
+		errs = append(errs, ValidationError{"pagination.window.start is required", location + ".start"})
+	} else if _, err := toTime(w.Start, format); err != nil {
+		errs = append(errs, ValidationError{fmt.Sprintf("invalid pagination.window.start: %v", err), location + ".start"})
+	}
+	if w.End != "" {
+		if _, err := toTime(w.End, format); err != nil {
+			errs = append(errs, ValidationError{fmt.Sprintf("invalid pagination.window.end: %v", err), location + ".end"})
+		}
+	}
+
+	ref := time.Now()
+	size, err := windowDuration(ref, w.Size)
+	if err != nil {
+		errs = append(errs, ValidationError{fmt.Sprintf("pagination.window.size must be a positive duration (e.g. '7d'): %v", err), location + ".size"})
+	}
+	if w.Overlap != "" {
+		overlap, err := windowDuration(ref, w.Overlap)
+		if err != nil {
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.window.overlap must be a positive duration (e.g. '1h'): %v", err), location + ".overlap"})
+		} else if size > 0 && overlap >= size {
+			errs = append(errs, ValidationError{"pagination.window.overlap must be shorter than the window size", location + ".overlap"})
+		}
+	}
+
+	for _, bound := range []struct {
+		WindowBound
+		field string
+	}{{w.From, "from"}, {w.To, "to"}} {
+		if bound.Name == "" {
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.window.%s.name is required", bound.field), location + "." + bound.field + ".name"})
+		}
+		if bound.Location != "query" && bound.Location != "body" && bound.Location != "header" {
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.window.%s.location must be one of [query, body, header]", bound.field), location + "." + bound.field + ".location"})
+		}
+		if _, err := loadWindowLocation(bound.Timezone); err != nil {
+			errs = append(errs, ValidationError{err.Error(), location + "." + bound.field + ".timezone"})
+		}
+	}
+
+	if p.NextPageUrlSelector != "" || p.Total != nil {
+		errs = append(errs, ValidationError{"pagination.window cannot be combined with nextPageUrlSelector or total", location})
+	}
+
+	return errs
+}
+
+// validatePaginationTotal checks that the pages after the first one can be
+// requested without their previous responses
+func validatePaginationTotal(p Pagination, location string) []ValidationError {
+	var errs []ValidationError
+
+	total := p.Total
+	if total.Selector == "" {
+		errs = append(errs, ValidationError{"pagination.total.selector is required", location + ".selector"})
+	}
+	switch total.Unit {
+	case "", PaginationTotalPages:
+	case PaginationTotalItems:
+		if total.PageSize <= 0 {
+			errs = append(errs, ValidationError{"pagination.total.pageSize must be positive when unit is items", location + ".pageSize"})
+		}
+	default:
+		errs = append(errs, ValidationError{fmt.Sprintf("pagination.total.unit must be one of [pages, items], got '%s'", total.Unit), location + ".unit"})
+	}
+
+	if p.NextPageUrlSelector != "" {
+		errs = append(errs, ValidationError{"pagination.total cannot be combined with nextPageUrlSelector", location})
+	}
+	for _, param := range p.Params {
+		if strings.ToLower(param.Type) == "dynamic" {
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination.total cannot be combined with the dynamic param '%s'", param.Name), location})
+		}
+	}
+
 	return errs
 }
 
@@ -392,6 +758,13 @@ func validatePaginationParam(param Param, location string) []ValidationError {
 	if typ == "dynamic" && param.Source == "" {
 		errs = append(errs, ValidationError{"pagination param source is required when type is dynamic", location + ".source"})
 	}
+	if param.Template != "" {
+		if param.Location == "body" {
+			errs = append(errs, ValidationError{"pagination param template is only supported for query and header params", location + ".template"})
+		} else if _, err := compileTemplate(param.Template); err != nil {
+			errs = append(errs, ValidationError{err.Error(), location + ".template"})
+		}
+	}
 	// Default can be anything, skipping type check here
 
 	return errs
@@ -400,15 +773,32 @@ func validatePaginationParam(param Param, location string) []ValidationError {
 func validatePaginationStop(stop StopCondition, location string) []ValidationError {
 	var errs []ValidationError
 
+	// all/any groups take the place of the type
+	if len(stop.All) > 0 || len(stop.Any) > 0 {
+		if stop.Type != "" {
+			errs = append(errs, ValidationError{"pagination stop type cannot be combined with all or any", location + ".type"})
+		}
+		if len(stop.All) > 0 && len(stop.Any) > 0 {
+			errs = append(errs, ValidationError{"pagination stop cannot have both all and any, nest one in the other instead", location})
+		}
+		for i, nested := range stop.All {
+			errs = append(errs, validatePaginationStop(nested, fmt.Sprintf("%s.all[%d]", location, i))...)
+		}
+		for i, nested := range stop.Any {
+			errs = append(errs, validatePaginationStop(nested, fmt.Sprintf("%s.any[%d]", location, i))...)
+		}
+		return errs
+	}
+
 	t := strings.ToLower(stop.Type)
-	validTypes := map[string]bool{"responsebody": true, "requestparam": true, "pagenum": true}
+	validTypes := map[string]bool{"responsebody": true, "requestparam": true, "pagenum": true, "expression": true}
 	if !validTypes[t] {
-		errs = append(errs, ValidationError{"pagination stop type must be one of [responseBody, requestParam, pageNum]", location + ".type"})
+		errs = append(errs, ValidationError{"pagination stop type must be one of [responseBody, requestParam, pageNum, expression] unless all or any is set", location + ".type"})
 	}
 
-	if t == "responsebody" {
+	if t == "responsebody" || t == "expression" {
 		if stop.Expression == "" {
-			errs = append(errs, ValidationError{"pagination stop expression is required when type is responseBody", location + ".expression"})
+			errs = append(errs, ValidationError{fmt.Sprintf("pagination stop expression is required when type is %s", stop.Type), location + ".expression"})
 		}
 	}
 
@@ -432,9 +822,8 @@ func validatePaginationStop(stop StopCondition, location string) []ValidationErr
 
 	if t == "pagenum" {
 		// For pageNum type, value is required
-		_, ok := stop.Value.(int)
-		if stop.Value == nil || !ok {
-			errs = append(errs, ValidationError{"pagination stop value is required and mut be an int when type is pageNum", location + ".value"})
+		if _, err := stopPageNum(stop.Value); err != nil {
+			errs = append(errs, ValidationError{"pagination stop value is required and must be a whole number when type is pageNum", location + ".value"})
 		}
 		// No other fields required
 	}
@@ -171,7 +171,7 @@ func TestVariablesComplex(t *testing.T) {
 		},
 	}
 
-	result := craw.contextMapToTemplate(contextMap, vars)
+	result := craw.contextMapToTemplate(newContextChain(contextMap), vars, nil)
 
 	// Verify complex vars are accessible
 	auth, ok := result["auth"].(map[string]any)
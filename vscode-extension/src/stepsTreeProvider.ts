@@ -67,7 +67,11 @@ export const ProfileEventType = {
     // Errors
     EVENT_ERROR: 27,
 
-    EVENT_MAX_NUM: 27
+    // Events appended after EVENT_ERROR (values are stable)
+    EVENT_REQUEST_RETRY: 28,
+    EVENT_STEP_CONDITION: 29,
+
+    EVENT_MAX_NUM: 29
 } as const;
 
 export class StepTreeItem extends vscode.TreeItem {
@@ -172,7 +176,9 @@ export class StepTreeItem extends vscode.TreeItem {
             [ProfileEventType.EVENT_AUTH_END]: 'Auth End',
             [ProfileEventType.EVENT_RESULT]: 'Final Result',
             [ProfileEventType.EVENT_STREAM_RESULT]: 'Stream Result',
-            [ProfileEventType.EVENT_ERROR]: 'Error'
+            [ProfileEventType.EVENT_ERROR]: 'Error',
+            [ProfileEventType.EVENT_REQUEST_RETRY]: 'Retry',
+            [ProfileEventType.EVENT_STEP_CONDITION]: 'Condition'
         };
 
         return eventNames[this.data.type] || `Unknown Event (${this.data.type})`;
@@ -464,6 +470,10 @@ export class StepsTreeProvider implements vscode.TreeDataProvider<StepTreeItem>
                 return 'Final Result';
             case ProfileEventType.EVENT_STREAM_RESULT:
                 return `Stream Result ${data.data?.index ?? ''}`;
+            case ProfileEventType.EVENT_REQUEST_RETRY:
+                return `Retry ${data.data?.attempt ?? ''}${data.data?.statusCode ? ` (${data.data.statusCode})` : ''}`;
+            case ProfileEventType.EVENT_STEP_CONDITION:
+                return `Condition: ${data.data?.skipped ? 'skipped' : 'evaluated'}`;
             default:
                 return 'Step';
         }
@@ -536,7 +546,9 @@ export class StepsTreeProvider implements vscode.TreeDataProvider<StepTreeItem>
             [ProfileEventType.EVENT_AUTH_END]: 'Auth End',
             [ProfileEventType.EVENT_RESULT]: 'Final Result',
             [ProfileEventType.EVENT_STREAM_RESULT]: 'Stream Result',
-            [ProfileEventType.EVENT_ERROR]: 'Error'
+            [ProfileEventType.EVENT_ERROR]: 'Error',
+            [ProfileEventType.EVENT_REQUEST_RETRY]: 'Retry',
+            [ProfileEventType.EVENT_STEP_CONDITION]: 'Condition'
         };
 
         parts.push(`Event: ${eventNames[data.type] || `Unknown Event (${data.type})`}`);